@@ -0,0 +1,94 @@
+package bib
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/jung-kurt/gofpdf/contrib/gofpdi"
+)
+
+// ImportedPage is a pdf page imported to a document, size is in mm.
+type ImportedPage struct {
+	Template int
+	Width    float64
+	Height   float64
+}
+
+// SetBackground makes the first page of the pdf file a background of every next bib.
+// Like pdftk background, the page is scaled to fit the bib keeping its aspect ratio.
+// Empty file name removes the background.
+func (renderer *Renderer) SetBackground(bgFileName string) (err error) {
+	if len(bgFileName) == 0 {
+		renderer.background = nil
+		return nil
+	}
+
+	page, err := renderer.backgroundPage(bgFileName)
+	if err != nil {
+		return err
+	}
+
+	renderer.background = page
+	return nil
+}
+
+// backgroundPage imports the pdf once per document.
+func (renderer *Renderer) backgroundPage(bgFileName string) (page *ImportedPage, err error) {
+	page, ok := renderer.backgrounds[bgFileName]
+	if !ok {
+		page, err = ImportPage(renderer.pdf, renderer.importer, bgFileName)
+		if err != nil {
+			return nil, err
+		}
+		renderer.backgrounds[bgFileName] = page
+	}
+	return page, nil
+}
+
+// ImportPage imports the first page of the pdf file to the document.
+// It wraps gofpdi which panics on unreadable files.
+func ImportPage(pdf *gofpdf.Fpdf, importer *gofpdi.Importer, pdfFileName string) (page *ImportedPage, err error) {
+	if _, err := os.Stat(pdfFileName); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v: can't import page: %v", pdfFileName, r)
+		}
+	}()
+
+	template := importer.ImportPage(pdf, pdfFileName, 1, "/MediaBox")
+
+	// Sizes are in points
+	mediaBox := importer.GetPageSizes()[1]["/MediaBox"]
+	k := pdf.GetConversionRatio()
+
+	page = &ImportedPage{
+		Template: template,
+		Width:    mediaBox["w"] / k,
+		Height:   mediaBox["h"] / k,
+	}
+
+	return page, pdf.Error()
+}
+
+func (renderer *Renderer) drawBackground(page *ImportedPage) {
+	if page == nil {
+		return
+	}
+
+	pageSize := renderer.template.Page
+
+	w := pageSize.Width
+	h := pageSize.Height
+	if page.Width > 0 && page.Height > 0 {
+		scale := math.Min(pageSize.Width/page.Width, pageSize.Height/page.Height)
+		w = page.Width * scale
+		h = page.Height * scale
+	}
+
+	renderer.importer.UseImportedTemplate(renderer.pdf, page.Template, (pageSize.Width-w)/2, (pageSize.Height-h)/2, w, h)
+}
@@ -0,0 +1,170 @@
+package bib
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Code types
+const (
+	CodeQR      = "qr"
+	CodeCode128 = "code128"
+)
+
+var codeTypes = []string{CodeQR, CodeCode128}
+
+// Quiet zones around codes required by scanners, in modules (bars)
+const (
+	qrQuietZone      = 4
+	code128QuietZone = 10
+)
+
+// Code is a QR code or a barcode drawn in the box. The box includes the quiet
+// zone which is filled with white, QR code is centered in it.
+type Code struct {
+	Type   string  `json:"type"` // qr or code128
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Color  string  `json:"color,omitempty"` // #RRGGBB, black by default
+
+	// Data is the encoded text, fields are referred as {field}: "{event}-{number}".
+	// Default is "{number}". The code is skipped and reported if any of the
+	// fields is empty.
+	Data string `json:"data,omitempty"`
+}
+
+var codeFieldRegexp = regexp.MustCompile(`\{([^{}]*)\}`)
+
+func (code Code) data() string {
+	if len(code.Data) == 0 {
+		return "{" + FieldNumber + "}"
+	}
+	return code.Data
+}
+
+func (code Code) validate() error {
+	valid := false
+	for _, t := range codeTypes {
+		valid = valid || t == code.Type
+	}
+	if !valid {
+		return fmt.Errorf("unknown type %q, expected one of %v", code.Type, codeTypes)
+	}
+	if code.Width <= 0 || code.Height <= 0 {
+		return fmt.Errorf("invalid size %vx%v", code.Width, code.Height)
+	}
+	if _, _, _, err := parseColor(code.Color); err != nil {
+		return err
+	}
+	for _, match := range codeFieldRegexp.FindAllStringSubmatch(code.data(), -1) {
+		if !validField(match[1]) {
+			return fmt.Errorf("unknown field %q in data, expected one of %v", match[1], fields)
+		}
+	}
+	return nil
+}
+
+// content substitutes bib fields to the code data. If any of them is empty,
+// content is empty and the empty fields are returned.
+func (code Code) content(bib Bib) (content string, empty []string) {
+	content = codeFieldRegexp.ReplaceAllStringFunc(code.data(), func(match string) string {
+		field := strings.Trim(match, "{}")
+		value := bib.Value(field)
+		if len(value) == 0 {
+			empty = append(empty, field)
+		}
+		return value
+	})
+	if len(empty) != 0 {
+		return "", empty
+	}
+	return content, nil
+}
+
+// drawCode draws the code as vector rectangles, so it stays sharp at any size.
+func drawCode(pdf *gofpdf.Fpdf, code Code, content string) error {
+	if len(content) == 0 {
+		return nil
+	}
+
+	var encoded barcode.Barcode
+	var err error
+	var quietZone float64
+
+	switch code.Type {
+	case CodeQR:
+		encoded, err = qr.Encode(content, qr.M, qr.Auto)
+		quietZone = qrQuietZone
+	case CodeCode128:
+		encoded, err = code128.Encode(content)
+		quietZone = code128QuietZone
+	}
+	if err != nil {
+		return fmt.Errorf("can't encode %q to %v: %v", content, code.Type, err)
+	}
+
+	bounds := encoded.Bounds()
+	columns := bounds.Dx()
+	rows := bounds.Dy()
+
+	// Module size and the area covered by the code with its quiet zone
+	x, y, w, h := code.X, code.Y, code.Width, code.Height
+	var moduleWidth, moduleHeight float64
+
+	if code.Type == CodeQR {
+		moduleWidth = math.Min(w, h) / (float64(columns) + 2*quietZone)
+		moduleHeight = moduleWidth
+		size := moduleWidth * (float64(columns) + 2*quietZone)
+		x += (w - size) / 2
+		y += (h - size) / 2
+		w, h = size, size
+	} else {
+		// One dimensional code is one pixel high, bars take the whole height
+		moduleWidth = w / (float64(columns) + 2*quietZone)
+		moduleHeight = h / float64(rows)
+	}
+
+	pdf.SetFillColor(255, 255, 255)
+	pdf.Rect(x, y, w, h, "F")
+
+	r, g, b, _ := parseColor(code.Color)
+	pdf.SetFillColor(r, g, b)
+
+	left := x + moduleWidth*quietZone
+	top := y
+	if code.Type == CodeQR {
+		top += moduleHeight * quietZone
+	}
+
+	// Adjacent dark modules of a row are joined to a single rectangle
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; {
+			if !dark(encoded, bounds.Min.X+column, bounds.Min.Y+row) {
+				column++
+				continue
+			}
+			start := column
+			for column < columns && dark(encoded, bounds.Min.X+column, bounds.Min.Y+row) {
+				column++
+			}
+			pdf.Rect(left+float64(start)*moduleWidth, top+float64(row)*moduleHeight,
+				float64(column-start)*moduleWidth, moduleHeight, "F")
+		}
+	}
+
+	return pdf.Error()
+}
+
+func dark(encoded barcode.Barcode, x, y int) bool {
+	r, g, b, _ := encoded.At(x, y).RGBA()
+	return r+g+b < 3*0x8000
+}
@@ -0,0 +1,86 @@
+package bib
+
+import (
+	"fmt"
+)
+
+// Text pages layout, sizes are in mm except the font size
+const (
+	textPageMargin     = 10.0
+	textPageFontSize   = 14.0 // pt
+	textPageLineHeight = 7.0
+	textPageColumns    = 2
+)
+
+// AddTextPage adds a page with plain text lines, e.g. a cover page of the document.
+// Lines are drawn in columns with the font of the first template box, more pages
+// are added if lines don't fit.
+func (renderer *Renderer) AddTextPage(lines []string) error {
+	pdf := renderer.pdf
+
+	if len(renderer.template.Boxes) == 0 {
+		return fmt.Errorf("template has no boxes to take the font from")
+	}
+	chain := renderer.fonts[renderer.template.Boxes[0].Font]
+
+	page := renderer.template.Page
+	columnWidth := (page.Width - 2*textPageMargin) / textPageColumns
+	linesPerColumn := int((page.Height - 2*textPageMargin) / textPageLineHeight)
+	if linesPerColumn < 1 {
+		linesPerColumn = 1
+	}
+
+	pdf.SetTextColor(0, 0, 0)
+
+	for i := 0; i == 0 || i < len(lines); i += linesPerColumn {
+		column := (i / linesPerColumn) % textPageColumns
+		if column == 0 {
+			pdf.AddPage()
+		}
+
+		end := i + linesPerColumn
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		x := textPageMargin + float64(column)*columnWidth
+		drawLines(pdf, chain, textPageFontSize, x, textPageMargin, columnWidth, textPageLineHeight, lines[i:end], "L")
+	}
+
+	return pdf.Error()
+}
+
+// Bookmark adds an outline entry pointing to the current page, level 0 is the top.
+func (renderer *Renderer) Bookmark(title string, level int) {
+	// gofpdf writes bookmarks as UTF-16 only while a UTF-8 font is selected,
+	// otherwise non-latin titles are garbled.
+	if font := renderer.utf8Font(); font != nil {
+		renderer.pdf.SetFont(font.family, "", textPageFontSize)
+	}
+
+	renderer.pdf.Bookmark(title, level, 0)
+}
+
+// BookmarkPage adds an outline entry pointing to an already added page.
+func (renderer *Renderer) BookmarkPage(title string, level int, page int) {
+	current := renderer.pdf.PageNo()
+	renderer.pdf.SetPage(page)
+	renderer.Bookmark(title, level)
+	renderer.pdf.SetPage(current)
+}
+
+// PageNo returns the current page number, starting from 1.
+func (renderer *Renderer) PageNo() int {
+	return renderer.pdf.PageNo()
+}
+
+func (renderer *Renderer) utf8Font() *chainFont {
+	for _, chain := range renderer.fonts {
+		for _, font := range chain {
+			if font.utf8() {
+				return font
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package bib
+
+import (
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	fitStep  = 0.5 // pt
+	ellipsis = "…"
+)
+
+// fittedText is text laid out in a box.
+type fittedText struct {
+	fontSize   float64
+	lineHeight float64 // mm
+	lines      []string
+	truncated  bool
+}
+
+// fitText finds the biggest font size between box.MinSize and box.Size at which
+// text fits the box. If it doesn't fit even at the minimal size, text is truncated.
+func fitText(pdf *gofpdf.Fpdf, chain fontChain, box Box, text string) (fitted fittedText) {
+	maxWidth := box.Width - 2*pdf.GetCellMargin()
+
+	minSize := box.MinSize
+	if minSize == 0 || minSize > box.Size {
+		minSize = box.Size
+	}
+
+	for size := box.Size; size >= minSize; size -= fitStep {
+		fitted = layoutText(pdf, chain, box, text, size)
+		if fitted.fits(pdf, chain, box, maxWidth) {
+			return fitted
+		}
+	}
+
+	fitted = layoutText(pdf, chain, box, text, minSize)
+	fitted.truncate(pdf, chain, box, maxWidth)
+
+	return fitted
+}
+
+func (box Box) singleLine() bool {
+	return box.SingleLine || box.LineHeight == 0
+}
+
+func layoutText(pdf *gofpdf.Fpdf, chain fontChain, box Box, text string, fontSize float64) (fitted fittedText) {
+	fitted.fontSize = fontSize
+	fitted.lineHeight = pdf.PointConvert(fontSize) * box.LineHeight
+
+	if box.singleLine() {
+		fitted.lines = []string{strings.Join(strings.Fields(text), " ")}
+	} else {
+		fitted.lines = chain.wrap(pdf, fontSize, text, box.Width-2*pdf.GetCellMargin())
+	}
+
+	return
+}
+
+func (fitted *fittedText) maxLines(box Box) int {
+	if box.singleLine() {
+		return 1
+	}
+	if box.Height == 0 || fitted.lineHeight == 0 {
+		return len(fitted.lines)
+	}
+	maxLines := int(box.Height / fitted.lineHeight)
+	if maxLines < 1 {
+		maxLines = 1
+	}
+	return maxLines
+}
+
+func (fitted *fittedText) fits(pdf *gofpdf.Fpdf, chain fontChain, box Box, maxWidth float64) bool {
+	if len(fitted.lines) > fitted.maxLines(box) {
+		return false
+	}
+	for _, line := range fitted.lines {
+		if chain.width(pdf, fitted.fontSize, line) > maxWidth {
+			return false
+		}
+	}
+	return true
+}
+
+// truncate drops lines which don't fit the box height and cuts too long lines with ellipsis.
+func (fitted *fittedText) truncate(pdf *gofpdf.Fpdf, chain fontChain, box Box, maxWidth float64) {
+	maxLines := fitted.maxLines(box)
+
+	if len(fitted.lines) > maxLines {
+		rest := strings.Join(fitted.lines[maxLines-1:], " ")
+		fitted.lines = append(fitted.lines[:maxLines-1], rest)
+		fitted.truncated = true
+	}
+
+	for i, line := range fitted.lines {
+		if chain.width(pdf, fitted.fontSize, line) <= maxWidth {
+			continue
+		}
+
+		fitted.truncated = true
+
+		runes := []rune(line)
+		for n := len(runes) - 1; n >= 0; n-- {
+			candidate := strings.TrimSpace(string(runes[:n])) + ellipsis
+			if n == 0 || chain.width(pdf, fitted.fontSize, candidate) <= maxWidth {
+				fitted.lines[i] = candidate
+				break
+			}
+		}
+	}
+}
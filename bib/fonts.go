@@ -0,0 +1,289 @@
+package bib
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font/sfnt"
+)
+
+// chainFont is a font registered in pdf. Legacy gofpdf fonts (.json) are single
+// byte encoded with the given code page, TrueType fonts (.ttf) are embedded as UTF-8.
+type chainFont struct {
+	family   string
+	encoding map[rune]byte
+	glyphs   *sfnt.Font
+	buffer   sfnt.Buffer
+}
+
+// fontChain is a list of fonts tried in order for every character, so text
+// which is not covered by the first font is drawn with the next one.
+type fontChain []*chainFont
+
+type textRun struct {
+	font *chainFont
+	text string
+}
+
+// loadFontChain registers fonts from spec like ["helvetica_1251.json:cp1251", "DejaVuSansCondensed.ttf"].
+// Files are looked up in fontDir, code page map for .json fonts is "<encoding>.map" in fontDir.
+func loadFontChain(pdf *gofpdf.Fpdf, fontDir string, spec []string) (chain fontChain, err error) {
+	for _, item := range spec {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		fileName := item
+		encodingName := ""
+		if i := strings.Index(item, ":"); i >= 0 {
+			fileName = item[:i]
+			encodingName = item[i+1:]
+		}
+
+		font := &chainFont{
+			family: strings.TrimSuffix(fileName, filepath.Ext(fileName)),
+		}
+
+		switch strings.ToLower(filepath.Ext(fileName)) {
+		case ".ttf":
+			data, err := ioutil.ReadFile(filepath.Join(fontDir, fileName))
+			if err != nil {
+				return nil, err
+			}
+			font.glyphs, err = sfnt.Parse(data)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", fileName, err)
+			}
+			pdf.AddUTF8Font(font.family, "", fileName)
+		case ".json":
+			if len(encodingName) == 0 {
+				return nil, fmt.Errorf("%v: encoding is not specified, use %v:cp1251", fileName, fileName)
+			}
+			font.encoding, err = readEncodingMap(filepath.Join(fontDir, encodingName+".map"))
+			if err != nil {
+				return nil, err
+			}
+			pdf.AddFont(font.family, "", fileName)
+		default:
+			return nil, fmt.Errorf("%v: unsupported font file, expected .ttf or .json", fileName)
+		}
+
+		if pdf.Err() {
+			return nil, pdf.Error()
+		}
+
+		chain = append(chain, font)
+	}
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no fonts in %q", spec)
+	}
+
+	return chain, nil
+}
+
+// readEncodingMap reads gofpdf code page map, lines look like "!C0 U+0410 afii10017".
+func readEncodingMap(mapFilePath string) (encoding map[rune]byte, err error) {
+	file, err := os.Open(mapFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	encoding = make(map[rune]byte)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[2] == ".notdef" {
+			continue
+		}
+		code, err := strconv.ParseUint(strings.TrimPrefix(fields[0], "!"), 16, 8)
+		if err != nil {
+			continue
+		}
+		r, err := strconv.ParseUint(strings.TrimPrefix(fields[1], "U+"), 16, 32)
+		if err != nil {
+			continue
+		}
+		encoding[rune(r)] = byte(code)
+	}
+
+	return encoding, scanner.Err()
+}
+
+func (font *chainFont) utf8() bool {
+	return font.glyphs != nil
+}
+
+func (font *chainFont) has(r rune) bool {
+	if font.utf8() {
+		index, err := font.glyphs.GlyphIndex(&font.buffer, r)
+		return err == nil && index != 0
+	}
+	_, ok := font.encoding[r]
+	return ok
+}
+
+func (font *chainFont) encode(s string) string {
+	if font.utf8() {
+		return s
+	}
+	encoded := make([]byte, 0, len(s))
+	for _, r := range s {
+		encoded = append(encoded, font.encoding[r])
+	}
+	return string(encoded)
+}
+
+// maxPdfRune is the last character gofpdf can embed, it only supports the Basic Multilingual Plane.
+const maxPdfRune = 0xFFFF
+
+// fontFor returns nil if the character can't be drawn at all. Such characters
+// and the ones missing in all fonts are reported by missing.
+func (chain fontChain) fontFor(r rune) *chainFont {
+	if r > maxPdfRune {
+		return nil
+	}
+
+	for _, font := range chain {
+		if font.has(r) {
+			return font
+		}
+	}
+
+	// Missing glyph box of a TrueType font is better than a random code page character
+	for _, font := range chain {
+		if font.utf8() {
+			return font
+		}
+	}
+	return chain[0]
+}
+
+// missing lists characters of the text which can't be drawn properly: beyond
+// the Basic Multilingual Plane (emoji) or absent in all fonts.
+func (chain fontChain) missing(text string) string {
+	var missing []rune
+	seen := make(map[rune]bool)
+	for _, r := range text {
+		if seen[r] || r == ' ' {
+			continue
+		}
+		seen[r] = true
+		if r > maxPdfRune || !chain.hasAny(r) {
+			missing = append(missing, r)
+		}
+	}
+	return string(missing)
+}
+
+func (chain fontChain) hasAny(r rune) bool {
+	for _, font := range chain {
+		if font.has(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// runs splits text to pieces each drawn with a single font. Spaces stay with the
+// current font to avoid needless font switches.
+func (chain fontChain) runs(text string) (runs []textRun) {
+	var font *chainFont
+	var current []rune
+
+	for _, r := range text {
+		runeFont := font
+		if font == nil || (r != ' ' && !font.has(r)) {
+			runeFont = chain.fontFor(r)
+			if runeFont == nil {
+				continue
+			}
+		}
+		if runeFont != font && len(current) != 0 {
+			runs = append(runs, textRun{font: font, text: font.encode(string(current))})
+			current = current[:0]
+		}
+		font = runeFont
+		current = append(current, r)
+	}
+
+	if len(current) != 0 {
+		runs = append(runs, textRun{font: font, text: font.encode(string(current))})
+	}
+
+	return
+}
+
+func (chain fontChain) width(pdf *gofpdf.Fpdf, fontSize float64, text string) (width float64) {
+	for _, run := range chain.runs(text) {
+		pdf.SetFont(run.font.family, "", fontSize)
+		width += pdf.GetStringWidth(run.text)
+	}
+	return
+}
+
+// wrap splits text to lines by words like MultiCell does.
+func (chain fontChain) wrap(pdf *gofpdf.Fpdf, fontSize float64, text string, maxWidth float64) (lines []string) {
+	line := ""
+
+	for _, word := range strings.Fields(text) {
+		if len(line) == 0 {
+			line = word
+			continue
+		}
+		candidate := line + " " + word
+		if chain.width(pdf, fontSize, candidate) > maxWidth {
+			lines = append(lines, line)
+			line = word
+		} else {
+			line = candidate
+		}
+	}
+
+	if len(line) != 0 {
+		lines = append(lines, line)
+	}
+
+	return
+}
+
+// drawLines is MultiCell which can switch fonts in the middle of a line.
+// Lines are expected to be wrapped already, see fitText.
+func drawLines(pdf *gofpdf.Fpdf, chain fontChain, fontSize, x, y, w, h float64, lines []string, align string) {
+	cellMargin := pdf.GetCellMargin()
+
+	pdf.SetCellMargin(0)
+	defer pdf.SetCellMargin(cellMargin)
+
+	for _, line := range lines {
+		lineWidth := chain.width(pdf, fontSize, line)
+
+		lineX := x + cellMargin
+		switch align {
+		case "C":
+			lineX = x + (w-lineWidth)/2
+		case "R":
+			lineX = x + w - cellMargin - lineWidth
+		}
+
+		for _, run := range chain.runs(line) {
+			pdf.SetFont(run.font.family, "", fontSize)
+			runWidth := pdf.GetStringWidth(run.text)
+			pdf.SetXY(lineX, y)
+			pdf.CellFormat(runWidth, h, run.text, "", 0, "L", false, 0, "")
+			lineX += runWidth
+		}
+
+		y += h
+	}
+}
@@ -0,0 +1,188 @@
+// Package bib draws start numbers (bibs) to pdf according to a layout template.
+package bib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/jung-kurt/gofpdf/contrib/gofpdi"
+)
+
+// Bib is the text printed on a single start number.
+type Bib struct {
+	Number   string
+	Name     string
+	Team     string
+	Category string
+	Event    string
+	ID       string
+
+	// Spare bib is printed without name for day-of registration.
+	Spare bool
+}
+
+// Value returns text of the template field.
+func (bib Bib) Value(field string) string {
+	switch field {
+	case FieldNumber:
+		return bib.Number
+	case FieldName:
+		return bib.Name
+	case FieldTeam:
+		return bib.Team
+	case FieldCategory:
+		return bib.Category
+	case FieldEvent:
+		return bib.Event
+	case FieldID:
+		return bib.ID
+	}
+	return ""
+}
+
+// Renderer draws bibs to a pdf document, one bib per page.
+type Renderer struct {
+	template *Template
+	pdf      *gofpdf.Fpdf
+	fonts    map[string]fontChain
+
+	importer    *gofpdi.Importer
+	backgrounds map[string]*ImportedPage
+	background  *ImportedPage
+}
+
+// NewRenderer creates an empty document. Fonts of the template are looked up in fontDir.
+func NewRenderer(template *Template, fontDir string) (renderer *Renderer, err error) {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr:    "mm",
+		Size:       gofpdf.SizeType{Wd: template.Page.Width, Ht: template.Page.Height},
+		FontDirStr: fontDir,
+	})
+
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+
+	renderer = &Renderer{
+		template: template,
+		pdf:      pdf,
+		fonts:    make(map[string]fontChain, len(template.Fonts)),
+
+		importer:    gofpdi.NewImporter(),
+		backgrounds: make(map[string]*ImportedPage),
+	}
+
+	for name, spec := range template.Fonts {
+		chain, err := loadFontChain(pdf, fontDir, spec)
+		if err != nil {
+			return nil, fmt.Errorf("font %q: %v", name, err)
+		}
+		renderer.fonts[name] = chain
+	}
+
+	return renderer, nil
+}
+
+// Truncation is a field which didn't fit its box even at the minimal font size,
+// or has characters which can't be drawn, or a code which is skipped.
+type Truncation struct {
+	Field   string // box field or code type
+	Text    string
+	Drawn   string
+	Missing string // characters skipped or drawn as missing glyph boxes, e.g. emoji
+	Empty   string // fields of the code data which are empty, the code is not drawn
+}
+
+// AddBib draws the bib on a new page. Fields which had to be truncated and
+// skipped codes are returned so they can be checked before printing.
+func (renderer *Renderer) AddBib(bib Bib) (truncations []Truncation, err error) {
+	pdf := renderer.pdf
+
+	variant := renderer.template.variant(bib)
+
+	background := renderer.background
+	if variant != nil && len(variant.Background) != 0 {
+		background, err = renderer.backgroundPage(variant.Background)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pdf.AddPage()
+	renderer.drawBackground(background)
+
+	if variant != nil {
+		for _, stripe := range variant.Stripes {
+			drawStripe(pdf, stripe)
+		}
+	}
+
+	if bib.Spare {
+		for _, line := range renderer.template.WriteIn {
+			drawStripe(pdf, line)
+		}
+	}
+
+	for _, box := range renderer.template.Boxes {
+		text := bib.Value(box.Field)
+		if len(text) == 0 || !box.If.matches(bib, text) {
+			continue
+		}
+
+		r, g, b, _ := parseColor(variant.color(box))
+		pdf.SetTextColor(r, g, b)
+
+		align := box.Align
+		if len(align) == 0 {
+			align = "L"
+		}
+
+		chain := renderer.fonts[box.Font]
+		fitted := fitText(pdf, chain, box, text)
+		missing := chain.missing(text)
+		if fitted.truncated || len(missing) != 0 {
+			drawn := strings.TrimSpace(strings.Map(func(r rune) rune {
+				if r > maxPdfRune {
+					return -1
+				}
+				return r
+			}, strings.Join(fitted.lines, " ")))
+			truncations = append(truncations, Truncation{
+				Field:   box.Field,
+				Text:    text,
+				Drawn:   drawn,
+				Missing: missing,
+			})
+		}
+
+		drawLines(pdf, chain, fitted.fontSize, box.X, box.Y, box.Width, fitted.lineHeight, fitted.lines, align)
+	}
+
+	for _, code := range renderer.template.Codes {
+		content, empty := code.content(bib)
+		if len(empty) != 0 {
+			truncations = append(truncations, Truncation{
+				Field: code.Type,
+				Text:  code.data(),
+				Empty: strings.Join(empty, ", "),
+			})
+		}
+		err = drawCode(pdf, code, content)
+		if err != nil {
+			return truncations, err
+		}
+	}
+
+	return truncations, pdf.Error()
+}
+
+func drawStripe(pdf *gofpdf.Fpdf, stripe Stripe) {
+	r, g, b, _ := parseColor(stripe.Color)
+	pdf.SetFillColor(r, g, b)
+	pdf.Rect(stripe.X, stripe.Y, stripe.Width, stripe.Height, "F")
+}
+
+// OutputFileAndClose writes the document.
+func (renderer *Renderer) OutputFileAndClose(fileName string) error {
+	return renderer.pdf.OutputFileAndClose(fileName)
+}
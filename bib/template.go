@@ -0,0 +1,219 @@
+package bib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Bib fields which can be drawn in a box.
+const (
+	FieldNumber   = "number"
+	FieldName     = "name"
+	FieldTeam     = "team"
+	FieldCategory = "category"
+	FieldEvent    = "event" // event id, e.g. for codes scanned at check-in
+	FieldID       = "id"    // participant id, registration timestamp in render-numbers
+)
+
+var fields = []string{FieldNumber, FieldName, FieldTeam, FieldCategory, FieldEvent, FieldID}
+
+// Template describes bib design: page size, fonts and text boxes. All sizes are
+// in mm except font sizes which are in pt.
+type Template struct {
+	Page  Page                `json:"page"`
+	Fonts map[string][]string `json:"fonts"` // name -> font chain, see loadFontChain
+	Boxes []Box               `json:"boxes"`
+	Codes []Code              `json:"codes,omitempty"`
+
+	// Variants change colors, background and stripes by category, first matching is used.
+	Variants []Variant `json:"variants,omitempty"`
+
+	// WriteIn are lines drawn on spare bibs only, to write the name in by hand.
+	WriteIn []Stripe `json:"writeIn,omitempty"`
+}
+
+type Page struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Box is a place where a field is drawn. Several boxes may refer to the same field
+// with different conditions, e.g. number is bigger when there is no team.
+type Box struct {
+	Field  string  `json:"field"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height,omitempty"` // 0 is unlimited
+	Font   string  `json:"font"`
+	Size   float64 `json:"size"`
+	Align  string  `json:"align,omitempty"` // L, C or R
+	Color  string  `json:"color,omitempty"` // #RRGGBB, black by default
+
+	// MinSize enables shrink-to-fit: font is reduced from Size down to MinSize
+	// until text fits the box, after that text is truncated with ellipsis.
+	MinSize float64 `json:"minSize,omitempty"`
+
+	// SingleLine disables wrapping by words.
+	SingleLine bool `json:"singleLine,omitempty"`
+
+	// LineHeight is relative to font size. Zero means single line vertically
+	// centered at Y, otherwise text starts at Y and wraps by words.
+	LineHeight float64 `json:"lineHeight,omitempty"`
+
+	If *Condition `json:"if,omitempty"`
+}
+
+// Condition limits a box to bibs with (or without) some fields filled.
+type Condition struct {
+	Present   []string `json:"present,omitempty"`
+	Absent    []string `json:"absent,omitempty"`
+	MinLength int      `json:"minLength,omitempty"` // of the box field text
+	MaxLength int      `json:"maxLength,omitempty"`
+}
+
+// ReadTemplateFile reads and validates the template.
+func ReadTemplateFile(templateFilePath string) (template *Template, err error) {
+	file, err := os.Open(templateFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+
+	template = new(Template)
+
+	err = decoder.Decode(template)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", templateFilePath, err)
+	}
+
+	err = template.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", templateFilePath, err)
+	}
+
+	for i := range template.Variants {
+		background := template.Variants[i].Background
+		if len(background) != 0 && !filepath.IsAbs(background) {
+			template.Variants[i].Background = filepath.Join(filepath.Dir(templateFilePath), background)
+		}
+	}
+
+	return template, nil
+}
+
+// Validate checks template consistency.
+func (template *Template) Validate() error {
+	if template.Page.Width <= 0 || template.Page.Height <= 0 {
+		return fmt.Errorf("invalid page size %vx%v", template.Page.Width, template.Page.Height)
+	}
+
+	for i, box := range template.Boxes {
+		if !validField(box.Field) {
+			return fmt.Errorf("box %v: unknown field %q, expected one of %v", i+1, box.Field, fields)
+		}
+		if _, ok := template.Fonts[box.Font]; !ok {
+			return fmt.Errorf("box %v: unknown font %q", i+1, box.Font)
+		}
+		if box.Size <= 0 {
+			return fmt.Errorf("box %v: invalid font size %v", i+1, box.Size)
+		}
+		if box.MinSize < 0 || box.MinSize > box.Size {
+			return fmt.Errorf("box %v: invalid min font size %v, expected 0..%v", i+1, box.MinSize, box.Size)
+		}
+		if box.Width <= 0 || box.Height < 0 {
+			return fmt.Errorf("box %v: invalid size %vx%v", i+1, box.Width, box.Height)
+		}
+		switch box.Align {
+		case "", "L", "C", "R":
+		default:
+			return fmt.Errorf("box %v: invalid align %q, expected L, C or R", i+1, box.Align)
+		}
+		if _, _, _, err := parseColor(box.Color); err != nil {
+			return fmt.Errorf("box %v: %v", i+1, err)
+		}
+		if box.If != nil {
+			for _, field := range append(append([]string{}, box.If.Present...), box.If.Absent...) {
+				if !validField(field) {
+					return fmt.Errorf("box %v: unknown field %q in condition", i+1, field)
+				}
+			}
+		}
+	}
+
+	for i, code := range template.Codes {
+		if err := code.validate(); err != nil {
+			return fmt.Errorf("code %v: %v", i+1, err)
+		}
+	}
+
+	for i := range template.Variants {
+		if err := template.Variants[i].validate(); err != nil {
+			return fmt.Errorf("variant %v: %v", i+1, err)
+		}
+	}
+
+	for i, line := range template.WriteIn {
+		if err := line.validate(); err != nil {
+			return fmt.Errorf("write-in line %v: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func validField(field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (condition *Condition) matches(bib Bib, text string) bool {
+	if condition == nil {
+		return true
+	}
+	for _, field := range condition.Present {
+		if len(bib.Value(field)) == 0 {
+			return false
+		}
+	}
+	for _, field := range condition.Absent {
+		if len(bib.Value(field)) != 0 {
+			return false
+		}
+	}
+	length := len([]rune(text))
+	if condition.MinLength != 0 && length < condition.MinLength {
+		return false
+	}
+	if condition.MaxLength != 0 && length > condition.MaxLength {
+		return false
+	}
+	return true
+}
+
+// parseColor parses "#RRGGBB", empty color is black.
+func parseColor(color string) (r, g, b int, err error) {
+	if len(color) == 0 {
+		return 0, 0, 0, nil
+	}
+
+	hex := strings.TrimPrefix(color, "#")
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid color %q, expected #RRGGBB", color)
+	}
+
+	return int(value >> 16 & 0xFF), int(value >> 8 & 0xFF), int(value & 0xFF), nil
+}
@@ -0,0 +1,84 @@
+package bib
+
+import (
+	"fmt"
+
+	"github.com/ivanzoid/race-numbers/category"
+)
+
+// Variant changes the look of bibs of some categories, e.g. red numbers for women.
+type Variant struct {
+	// Categories are patterns of bib categories: ["Ж*"], ["М5*", "М6*", "М7*"].
+	Categories category.Patterns `json:"categories"`
+
+	// Colors override text color of the boxes by field: {"number": "#C00000"}.
+	Colors map[string]string `json:"colors,omitempty"`
+
+	// Background replaces the background pdf, relative to the template file.
+	Background string `json:"background,omitempty"`
+
+	Stripes []Stripe `json:"stripes,omitempty"`
+}
+
+// Stripe is a filled rectangle drawn over the background, under the texts.
+type Stripe struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Color  string  `json:"color,omitempty"`
+}
+
+func (variant *Variant) validate() error {
+	if len(variant.Categories) == 0 {
+		return fmt.Errorf("no categories")
+	}
+	if err := variant.Categories.Validate(); err != nil {
+		return err
+	}
+	for field, color := range variant.Colors {
+		if !validField(field) {
+			return fmt.Errorf("unknown field %q in colors", field)
+		}
+		if _, _, _, err := parseColor(color); err != nil {
+			return err
+		}
+	}
+	for i, stripe := range variant.Stripes {
+		if err := stripe.validate(); err != nil {
+			return fmt.Errorf("stripe %v: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (stripe Stripe) validate() error {
+	if stripe.Width <= 0 || stripe.Height <= 0 {
+		return fmt.Errorf("invalid size %vx%v", stripe.Width, stripe.Height)
+	}
+	_, _, _, err := parseColor(stripe.Color)
+	return err
+}
+
+// variant returns the first variant matching the bib category, nil if none.
+func (template *Template) variant(bib Bib) *Variant {
+	if len(bib.Category) == 0 {
+		return nil
+	}
+	for i := range template.Variants {
+		if template.Variants[i].Categories.Match(bib.Category) {
+			return &template.Variants[i]
+		}
+	}
+	return nil
+}
+
+// color returns the box text color, overridden by the variant if any.
+func (variant *Variant) color(box Box) string {
+	if variant != nil {
+		if color, ok := variant.Colors[box.Field]; ok {
+			return color
+		}
+	}
+	return box.Color
+}
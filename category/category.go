@@ -0,0 +1,57 @@
+// Package category computes age categories of participants by birth date and gender.
+package category
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Genders as they are written in category labels.
+const (
+	Male   = "М"
+	Female = "Ж"
+)
+
+// DateLayouts are accepted formats of birth and event dates. Slash dates are
+// not accepted: sheets export them month first (7/27/2020), so 5/3/1990 is ambiguous.
+var DateLayouts = []string{"2.1.2006", "2006-01-02"}
+
+// ParseDate parses a date in any of DateLayouts.
+func ParseDate(value string) (date time.Time, err error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return date, fmt.Errorf("empty date")
+	}
+	for _, layout := range DateLayouts {
+		date, err = time.Parse(layout, value)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return date, fmt.Errorf("invalid date %q, expected day.month.year or year-month-day", value)
+}
+
+// NormalizeGender converts "м", "Мужской", "M", "male" and the like to Male or
+// Female, empty string if the gender is unknown.
+func NormalizeGender(gender string) string {
+	gender = strings.ToLower(strings.TrimSpace(gender))
+	switch {
+	case len(gender) == 0:
+		return ""
+	case strings.HasPrefix(gender, "м"), strings.HasPrefix(gender, "m"):
+		return Male
+	case strings.HasPrefix(gender, "ж"), strings.HasPrefix(gender, "f"), strings.HasPrefix(gender, "w"):
+		return Female
+	}
+	return ""
+}
+
+// Age is the number of full years between birth and the date.
+func Age(birth, at time.Time) int {
+	age := at.Year() - birth.Year()
+	if at.Month() < birth.Month() || (at.Month() == birth.Month() && at.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
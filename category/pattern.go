@@ -0,0 +1,36 @@
+package category
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Patterns are matched against the whole category, case insensitive, * matches
+// any text: ["Ж*"], ["М5*", "М6*", "М7*"].
+type Patterns []string
+
+// Validate checks pattern syntax.
+func (patterns Patterns) Validate() error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return fmt.Errorf("invalid category pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Match tells if the category matches any of the patterns.
+func (patterns Patterns) Match(category string) bool {
+	category = strings.ToLower(strings.TrimSpace(category))
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), category); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (patterns Patterns) String() string {
+	return strings.Join(patterns, ", ")
+}
@@ -0,0 +1,201 @@
+package category
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scheme is a set of age brackets by gender.
+//
+//	"categories": {
+//	  "ageRule": "yearEnd",
+//	  "brackets": [
+//	    {"gender": "М", "from": 18, "to": 29},
+//	    {"gender": "М", "from": 50, "label": "М50+", "description": "М50+ – мужчины 50 лет и старше"}
+//	  ]
+//	}
+type Scheme struct {
+	// AgeRule tells at which date age is computed, AgeAtEvent by default.
+	AgeRule AgeRule `json:"ageRule,omitempty"`
+
+	Brackets []Bracket `json:"brackets"`
+}
+
+// AgeRule is the date of the season age is computed at.
+type AgeRule string
+
+const (
+	AgeAtEvent       AgeRule = "event"       // full years on the event date
+	AgeAtYearEnd     AgeRule = "yearEnd"     // racing age: full years on 31 December of the event year (UCI)
+	AgeAtSeasonStart AgeRule = "seasonStart" // full years on 1 January of the event year
+)
+
+var ageRules = []AgeRule{AgeAtEvent, AgeAtYearEnd, AgeAtSeasonStart}
+
+// AgeDate returns the date age is computed at for the event date.
+func (rule AgeRule) AgeDate(event time.Time) time.Time {
+	switch rule {
+	case AgeAtYearEnd:
+		return time.Date(event.Year(), time.December, 31, 0, 0, 0, 0, event.Location())
+	case AgeAtSeasonStart:
+		return time.Date(event.Year(), time.January, 1, 0, 0, 0, 0, event.Location())
+	}
+	return event
+}
+
+// Bracket is an age range of a gender, both ends inclusive.
+type Bracket struct {
+	Gender string `json:"gender"` // Male or Female
+	From   int    `json:"from"`
+	To     int    `json:"to,omitempty"` // 0 means no upper limit
+
+	// Label is the short category name, "М40-49" if not set.
+	Label string `json:"label,omitempty"`
+
+	// Description is the long name, "М40-49 – мужчины 40-49 лет" if not set.
+	Description string `json:"description,omitempty"`
+}
+
+// DefaultScheme has brackets used before schemes were configurable. The youngest
+// brackets take everybody under 18, their names show the official ages.
+func DefaultScheme() Scheme {
+	return Scheme{Brackets: []Bracket{
+		{Gender: Female, From: 0, To: 39, Label: "Ж18-39", Description: "Ж18-39 – женщины 18-39 лет"},
+		{Gender: Female, From: 40, Label: "Ж40+"},
+		{Gender: Male, From: 0, To: 22, Label: "М18-22", Description: "М18-22 – мужчины 18-22 лет"},
+		{Gender: Male, From: 23, To: 29},
+		{Gender: Male, From: 30, To: 39},
+		{Gender: Male, From: 40, To: 49},
+		{Gender: Male, From: 50, Label: "М50+"},
+	}}
+}
+
+// OpenEnded tells if the bracket has no upper age limit.
+func (bracket *Bracket) OpenEnded() bool {
+	return bracket.To == 0
+}
+
+func (bracket *Bracket) contains(age int) bool {
+	return age >= bracket.From && (bracket.OpenEnded() || age <= bracket.To)
+}
+
+func (bracket *Bracket) String() string {
+	if len(bracket.Label) != 0 {
+		return bracket.Label
+	}
+	if bracket.OpenEnded() {
+		return fmt.Sprintf("%v%v+", bracket.Gender, bracket.From)
+	}
+	return fmt.Sprintf("%v%v-%v", bracket.Gender, bracket.From, bracket.To)
+}
+
+// Describe returns the long category name.
+func (bracket *Bracket) Describe() string {
+	if len(bracket.Description) != 0 {
+		return bracket.Description
+	}
+
+	who := "мужчины"
+	if bracket.Gender == Female {
+		who = "женщины"
+	}
+
+	ages := fmt.Sprintf("%v-%v лет", bracket.From, bracket.To)
+	if bracket.OpenEnded() {
+		ages = fmt.Sprintf("%v лет и старше", bracket.From)
+	}
+
+	return fmt.Sprintf("%v – %v %v", bracket, who, ages)
+}
+
+// Validate checks the scheme consistency: known genders, no overlapping brackets
+// and unique labels.
+func (scheme *Scheme) Validate() error {
+	if len(scheme.AgeRule) != 0 {
+		valid := false
+		for _, rule := range ageRules {
+			valid = valid || rule == scheme.AgeRule
+		}
+		if !valid {
+			return fmt.Errorf("invalid age rule %q, expected one of %v", scheme.AgeRule, ageRules)
+		}
+	}
+
+	labels := make(map[string]bool, len(scheme.Brackets))
+
+	for i := range scheme.Brackets {
+		bracket := &scheme.Brackets[i]
+
+		if bracket.Gender != Male && bracket.Gender != Female {
+			return fmt.Errorf("bracket %v: invalid gender %q, expected %q or %q", i+1, bracket.Gender, Male, Female)
+		}
+		if bracket.From < 0 || (!bracket.OpenEnded() && bracket.To < bracket.From) {
+			return fmt.Errorf("bracket %v: invalid ages %v-%v", i+1, bracket.From, bracket.To)
+		}
+
+		label := strings.ToLower(bracket.String())
+		if labels[label] {
+			return fmt.Errorf("bracket %v: duplicate label %q", i+1, bracket)
+		}
+		labels[label] = true
+
+		for j := 0; j < i; j++ {
+			other := &scheme.Brackets[j]
+			if other.Gender != bracket.Gender {
+				continue
+			}
+			if bracket.contains(other.From) || other.contains(bracket.From) {
+				return fmt.Errorf("bracket %v overlaps %v", bracket, other)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Category finds the bracket of a rider born on birth for the event at the
+// given date, age is computed according to the age rule.
+func (scheme *Scheme) Category(birth time.Time, gender string, event time.Time) (bracket *Bracket, age int, err error) {
+	if birth.After(event) {
+		return nil, 0, fmt.Errorf("birth date %v is after the event %v", birth.Format("2.1.2006"), event.Format("2.1.2006"))
+	}
+
+	age = Age(birth, scheme.AgeRule.AgeDate(event))
+	if age < 0 {
+		age = 0
+	}
+
+	normalized := NormalizeGender(gender)
+	if len(normalized) == 0 {
+		return nil, age, fmt.Errorf("unknown gender %q", gender)
+	}
+
+	for i := range scheme.Brackets {
+		bracket = &scheme.Brackets[i]
+		if bracket.Gender == normalized && bracket.contains(age) {
+			return bracket, age, nil
+		}
+	}
+
+	return nil, age, fmt.Errorf("no %v category for age %v", normalized, age)
+}
+
+// Compute parses the birth date and finds the category, see Category.
+func (scheme *Scheme) Compute(birthDate, gender string, event time.Time) (bracket *Bracket, age int, err error) {
+	birth, err := ParseDate(birthDate)
+	if err != nil {
+		return nil, 0, err
+	}
+	return scheme.Category(birth, gender, event)
+}
+
+// Find returns the bracket with the label, case insensitive, nil if none.
+func (scheme *Scheme) Find(label string) *Bracket {
+	for i := range scheme.Brackets {
+		if strings.EqualFold(scheme.Brackets[i].String(), strings.TrimSpace(label)) {
+			return &scheme.Brackets[i]
+		}
+	}
+	return nil
+}
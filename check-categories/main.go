@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ivanzoid/race-numbers/category"
+	"github.com/ivanzoid/race-numbers/event"
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+// ---------------------------------------------------------------------------
+// Utils
+// ---------------------------------------------------------------------------
+
+func dlog(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, args...)
+	fmt.Fprintf(os.Stderr, "\n")
+}
+
+// ---------------------------------------------------------------------------
+
+// Problems found in a registration
+const (
+	problemMismatch      = "mismatch"
+	problemUndeclared    = "no declared category"
+	problemUnknown       = "unknown declared category"
+	problemBirthDate     = "invalid birth date"
+	problemGender        = "missing gender"
+	problemUnknownGender = "unknown gender"
+	problemNoCategory    = "no category"
+)
+
+var problems = []string{problemMismatch, problemUndeclared, problemUnknown, problemBirthDate, problemGender, problemUnknownGender, problemNoCategory}
+
+// check is the result of checking a single registration.
+type check struct {
+	row      int
+	name     string
+	declared string
+	bracket  *category.Bracket
+	age      int
+	problem  string
+	details  string
+}
+
+// correctable tells if the declared category can be replaced with the computed one.
+func (c check) correctable() bool {
+	return c.bracket != nil && (c.problem == problemMismatch || c.problem == problemUndeclared || c.problem == problemUnknown)
+}
+
+func checkUser(scheme *category.Scheme, user participant.Participant, row int, date time.Time) (c check) {
+	c = check{row: row, name: user.Name(), declared: user.Category}
+
+	if len(strings.TrimSpace(user.BirthDate)) == 0 {
+		c.problem = problemBirthDate
+		c.details = "empty birth date"
+		return c
+	}
+	birth, err := category.ParseDate(user.BirthDate)
+	if err != nil {
+		c.problem = problemBirthDate
+		c.details = err.Error()
+		return c
+	}
+
+	switch {
+	case len(strings.TrimSpace(user.Gender)) == 0:
+		c.problem = problemGender
+		c.details = "empty gender"
+		return c
+	case len(category.NormalizeGender(user.Gender)) == 0:
+		c.problem = problemUnknownGender
+		c.details = fmt.Sprintf("%q", user.Gender)
+		return c
+	}
+
+	c.bracket, c.age, err = scheme.Category(birth, user.Gender, date)
+	if err != nil {
+		c.problem = problemNoCategory
+		c.details = err.Error()
+		return c
+	}
+
+	switch {
+	case len(c.declared) == 0:
+		c.problem = problemUndeclared
+	case scheme.Find(c.declared) == nil:
+		c.problem = problemUnknown
+	case scheme.Find(c.declared) != c.bracket:
+		c.problem = problemMismatch
+	}
+	if len(c.problem) != 0 {
+		c.details = fmt.Sprintf("computed %v, age %v", c.bracket, c.age)
+	}
+
+	return c
+}
+
+func writeReport(checks []check) error {
+	writer := csv.NewWriter(os.Stdout)
+
+	err := writer.Write([]string{"row", "name", "declared", "computed", "age", "problem", "details"})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range checks {
+		computed := ""
+		age := ""
+		if c.bracket != nil {
+			computed = c.bracket.String()
+			age = fmt.Sprintf("%v", c.age)
+		}
+		err = writer.Write([]string{fmt.Sprintf("%v", c.row), c.name, c.declared, computed, age, c.problem, c.details})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeCorrected copies the registration csv replacing declared categories
+// with computed ones. Long categories stay long: "М40-49 – мужчины 40-49 лет".
+func writeCorrected(fileName string, records [][]string, categoryIndex int, checks []check) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range checks {
+		if !c.correctable() {
+			continue
+		}
+		record := records[c.row-1]
+		value := c.bracket.String()
+		if len(strings.Fields(record[categoryIndex])) > 1 {
+			value = c.bracket.Describe()
+		}
+		record[categoryIndex] = value
+	}
+
+	writer := csv.NewWriter(file)
+	writer.WriteAll(records)
+	if err = writer.Error(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+var (
+	participantsFileName = ""
+	eventFileName        = ""
+	eventDate            = ""
+	correctedFileName    = ""
+)
+
+func main() {
+
+	flag.StringVar(&participantsFileName, "p", "", "Participants csv file")
+	flag.StringVar(&eventFileName, "event", "", "Event config json file (registration columns, date, categories)")
+	flag.StringVar(&eventDate, "date", "", "Event date, e.g. 19.09.2021, overrides the date of the event config")
+	flag.StringVar(&correctedFileName, "fix", "", "Write participants csv with corrected categories to this file")
+
+	flag.Parse()
+
+	if len(participantsFileName) == 0 {
+		flag.Usage()
+		return
+	}
+
+	config, err := event.ReadConfigFile(eventFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(eventDate) != 0 {
+		config.Date = eventDate
+	}
+
+	date, err := config.EventDate()
+	if err != nil {
+		log.Fatalf("%v, use -date or \"date\" of the event config", err)
+	}
+
+	columns := config.Columns.Require(participant.FieldBirthDate, participant.FieldGender, participant.FieldCategory)
+
+	// Require only marks mapped columns, declared categories can't be read without the mapping
+	mapped := false
+	for _, column := range columns {
+		mapped = mapped || column.Field == participant.FieldCategory
+	}
+	if !mapped {
+		log.Fatalf("Event config has no %q column, declared categories can't be checked", participant.FieldCategory)
+	}
+
+	records, err := participant.ReadCsvFile(participantsFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(records) == 0 {
+		log.Fatalf("%v is empty", participantsFileName)
+	}
+
+	users, err := participant.FromRecords(records, columns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	checks := make([]check, 0, len(users))
+	counts := make(map[string]int)
+
+	for i, user := range users {
+		c := checkUser(&config.Categories, user, i+2, date)
+		if len(c.problem) == 0 {
+			continue
+		}
+		counts[c.problem]++
+		checks = append(checks, c)
+		dlog("Row %v, %v: %v: %v", c.row, c.name, c.problem, c.details)
+	}
+
+	dlog("Checked %v participants, %v problems", len(users), len(checks))
+	for _, problem := range problems {
+		if counts[problem] != 0 {
+			dlog("  %v: %v", problem, counts[problem])
+		}
+	}
+
+	err = writeReport(checks)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(correctedFileName) != 0 {
+		indexes, err := columns.Indexes(records[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		categoryIndex, ok := indexes[participant.FieldCategory]
+		if !ok {
+			log.Fatalf("%v has no category column", participantsFileName)
+		}
+
+		err = writeCorrected(correctedFileName, records, categoryIndex, checks)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dlog("Corrected categories are written to %v, problems without computed category are left as is", correctedFileName)
+	}
+}
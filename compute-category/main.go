@@ -1,13 +1,16 @@
 package main
 
 import (
-	"flag"
-	"time"
-	"github.com/bearbin/go-age"
-	"os"
 	"encoding/csv"
-	"log"
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ivanzoid/race-numbers/category"
+	"github.com/ivanzoid/race-numbers/event"
+	"github.com/ivanzoid/race-numbers/participant"
 )
 
 func dlog(format string, args ...interface{}) {
@@ -15,120 +18,134 @@ func dlog(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "\n")
 }
 
-func categoryFromDateAndGender(dateString, gender string) string {
-	layout := "2.1.2006"
-	birthTime, _ := time.Parse(layout, dateString)
-	eventTime, _ := time.Parse(layout, "16.09.2018")
-
-	ageInt := age.AgeAt(birthTime, eventTime)
-
-	//dlog("date = %v  gender = %v", dateString, gender)
-	//dlog("birthdate = %v, age = %v", birthTime, ageInt)
-
-	if gender == "Ж" {
-		if ageInt < 40 {
-			return "Ж18-39"
-		} else {
-			return "Ж40+"
+// Age column headers of the output, the first one is used for a new column.
+// Category column is the one of the event config.
+var ageHeaders = []string{"age", "Возраст"}
+
+// columnIndex returns index of the column with any of the headers, appending
+// the column with the first header if absent.
+func columnIndex(records [][]string, headers ...string) int {
+	for i, h := range records[0] {
+		for _, header := range headers {
+			if strings.EqualFold(strings.TrimSpace(h), header) {
+				return i
+			}
 		}
-	} else {
-		if ageInt < 23 {
-			return "М18-22"
-		} else if ageInt < 30 {
-			return "М23-29"
-		} else if ageInt < 40 {
-			return "М30-39"
-		} else if ageInt < 50 {
-			return "М40-49"
-		} else {
-			return "М50+"
+	}
+	for i := range records {
+		value := ""
+		if i == 0 {
+			value = headers[0]
 		}
+		records[i] = append(records[i], value)
 	}
-	return ""
+	return len(records[0]) - 1
 }
 
-func readCsvFile(csvFilePath string) (records [][]string, err error) {
-	file, err := os.Open(csvFilePath)
-	if err != nil {
-		return nil, err
+func categoryHeader(columns participant.Columns) string {
+	for _, column := range columns {
+		if column.Field == participant.FieldCategory {
+			return column.Header
+		}
 	}
-
-	defer file.Close()
-
-	csvReader := csv.NewReader(file)
-	//csvReader.Comma = ';'
-
-	records, err = csvReader.ReadAll()
-
-	return
+	return string(participant.FieldCategory)
 }
 
-func csvRecordsToMap(records [][]string) (result []map[string]string) {
-
-	if len(records) == 0 {
-		return nil
-	}
+var (
+	participantsFileName = ""
+	eventFileName        = ""
+	eventDate            = ""
+	long                 = false
+)
 
-	result = make([]map[string]string, 0, len(records))
+func main() {
 
-	var header []string
+	flag.StringVar(&participantsFileName, "p", "", "Participants csv file")
+	flag.StringVar(&eventFileName, "event", "", "Event config json file (registration columns, date, categories)")
+	flag.StringVar(&eventDate, "date", "", "Event date, e.g. 19.09.2021, overrides the date of the event config")
+	flag.BoolVar(&long, "long", false, "Write long category descriptions")
 
-	for line, record := range records {
-		if line == 0 {
-			header = record
-			continue
-		}
+	flag.Parse()
 
-		recordMap := make(map[string]string)
+	if len(participantsFileName) == 0 {
+		flag.Usage()
+		return
+	}
 
-		for col, value := range record {
-			if col >= len(header) {
-				break
-			}
-			colName := header[col]
-			recordMap[colName] = value
-		}
+	config, err := event.ReadConfigFile(eventFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		result = append(result, recordMap)
+	if len(eventDate) != 0 {
+		config.Date = eventDate
 	}
 
-	return
-}
+	date, err := config.EventDate()
+	if err != nil {
+		log.Fatalf("%v, use -date or \"date\" of the event config", err)
+	}
 
-func participantsUsersFromCsvFile(csvFilePath string) (mapRecords []map[string]string, err error) {
+	columns := config.Columns.Require(participant.FieldBirthDate, participant.FieldGender)
 
-	records, err := readCsvFile(csvFilePath)
+	records, err := participant.ReadCsvFile(participantsFileName)
 	if err != nil {
-		return nil, err
+		log.Fatal(err)
+	}
+	if len(records) == 0 {
+		log.Fatalf("%v is empty", participantsFileName)
 	}
 
-	mapRecords = csvRecordsToMap(records)
+	users, err := participant.FromRecords(records, columns)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	return
-}
+	indexes, err := columns.Indexes(records[0])
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	categoryIndex, ok := indexes[participant.FieldCategory]
+	if !ok {
+		categoryIndex = columnIndex(records, categoryHeader(columns))
+	}
+	ageIndex := columnIndex(records, ageHeaders...)
 
-func main() {
+	failed := 0
 
-	participantsFileName := ""
-	flag.StringVar(&participantsFileName, "p", "", "Participants csv file")
+	// Same csv with category and age filled, other columns and rows are kept as is
+	for i, user := range users {
+		record := records[i+1]
 
-	flag.Parse()
+		bracket, age, err := config.Categories.Compute(user.BirthDate, user.Gender, date)
+		if err != nil {
+			dlog("Row %v, %v: %v, category is left as is", i+2, user.Name(), err)
+			failed++
+			record[ageIndex] = ""
+			continue
+		}
 
-	if len(participantsFileName) == 0 {
-		flag.Usage()
-		return
+		record[categoryIndex] = bracket.String()
+		if long {
+			record[categoryIndex] = bracket.Describe()
+		}
+		record[ageIndex] = fmt.Sprintf("%v", age)
 	}
 
-	users, err := participantsUsersFromCsvFile(participantsFileName)
-	if err != nil {
+	writer := csv.NewWriter(os.Stdout)
+	writer.WriteAll(records)
+	if err = writer.Error(); err != nil {
 		log.Fatal(err)
 	}
 
-	for _, user := range users {
-		dateString := user["Дата рождения"]
-		gender := user["Пол"]
-		category := categoryFromDateAndGender(dateString, gender)
-		fmt.Println(category)
+	if failed != 0 {
+		dlog("Warning: no category for %v participants", failed)
 	}
-}
\ No newline at end of file
+
+	rule := config.Categories.AgeRule
+	if len(rule) == 0 {
+		rule = category.AgeAtEvent
+	}
+	dlog("Age is computed at %v (%v)", rule.AgeDate(date).Format("2.1.2006"), rule)
+}
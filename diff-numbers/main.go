@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ivanzoid/race-numbers/numbering"
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+// ---------------------------------------------------------------------------
+// Utils
+// ---------------------------------------------------------------------------
+
+func dlog(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, args...)
+	fmt.Fprintf(os.Stderr, "\n")
+}
+
+// ---------------------------------------------------------------------------
+
+var (
+	oldFileName = ""
+	newFileName = ""
+	onlyNumbers = false
+)
+
+func main() {
+
+	flag.StringVar(&oldFileName, "old", "", "Previous participants_rated.csv")
+	flag.StringVar(&newFileName, "new", "", "Current participants_rated.csv")
+	flag.BoolVar(&onlyNumbers, "numbers", false, "Print only numbers to re-render, e.g. \"3,17-19\" for render-numbers")
+
+	flag.Parse()
+
+	if len(oldFileName) == 0 || len(newFileName) == 0 {
+		flag.Usage()
+		return
+	}
+
+	previous, err := participant.ReadFile(oldFileName, participant.RatedColumns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	current, err := participant.ReadFile(newFileName, participant.RatedColumns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	changes := numbering.Diff(previous, current)
+
+	dlog("Changes: %v", len(changes))
+	for _, change := range changes {
+		dlog("  %v", change)
+	}
+
+	numbers := numbering.Numbers(changes)
+	dlog("Numbers to re-render: %v", numbers)
+
+	if onlyNumbers {
+		fmt.Println(numbers)
+		return
+	}
+
+	err = numbering.WriteChanges(os.Stdout, changes)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
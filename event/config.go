@@ -0,0 +1,129 @@
+// Package event contains per-event configuration which changes every season:
+// registration sheet layout and the like.
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ivanzoid/race-numbers/category"
+	"github.com/ivanzoid/race-numbers/numbering"
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+// Config is the event configuration file.
+//
+//	{
+//	  "name": "Кубок Омска 2021",
+//	  "date": "19.09.2021",
+//	  "columns": [
+//	    {"field": "lastName", "header": "Фамилия", "required": true},
+//	    {"field": "team", "header": "Клуб/команда", "aliases": ["Команда"]}
+//	  ],
+//	  "numbering": {"reserved": "1,100-110", "spares": "290-299"},
+//	  "categories": {"brackets": [{"gender": "М", "from": 18, "to": 29}, {"gender": "М", "from": 30}]}
+//	}
+type Config struct {
+	Name string `json:"name"`
+
+	// Date of the event, day.month.year. Ages are computed at this date.
+	Date string `json:"date,omitempty"`
+
+	// Columns of the registration sheet. Defaults to participant.RegistrationColumns.
+	Columns participant.Columns `json:"columns"`
+
+	// Numbering are start number allocation rules.
+	Numbering numbering.Rules `json:"numbering"`
+
+	// Categories by age and gender. Defaults to category.DefaultScheme.
+	Categories category.Scheme `json:"categories"`
+}
+
+// DefaultConfig is used when no config file is given.
+func DefaultConfig() *Config {
+	return &Config{
+		Columns:    append(participant.Columns(nil), participant.RegistrationColumns...),
+		Categories: category.DefaultScheme(),
+	}
+}
+
+// ReadConfigFile reads and validates the config. Empty path gives DefaultConfig.
+func ReadConfigFile(configFilePath string) (config *Config, err error) {
+	if len(configFilePath) == 0 {
+		return DefaultConfig(), nil
+	}
+
+	file, err := os.Open(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+
+	// Decoding into default slices would keep fields omitted in the file, so
+	// defaults are set only for missing lists
+	config = new(Config)
+
+	err = decoder.Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", configFilePath, err)
+	}
+
+	defaults := DefaultConfig()
+	if config.Columns == nil {
+		config.Columns = defaults.Columns
+	}
+	if len(config.Categories.Brackets) == 0 {
+		config.Categories.Brackets = defaults.Categories.Brackets
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", configFilePath, err)
+	}
+
+	return config, nil
+}
+
+// Validate checks config consistency.
+func (config *Config) Validate() error {
+	if len(config.Columns) == 0 {
+		return fmt.Errorf("no columns")
+	}
+
+	err := config.Columns.Validate()
+	if err != nil {
+		return fmt.Errorf("columns: %v", err)
+	}
+
+	err = config.Numbering.Validate()
+	if err != nil {
+		return fmt.Errorf("numbering: %v", err)
+	}
+
+	if len(config.Date) != 0 {
+		if _, err = category.ParseDate(config.Date); err != nil {
+			return fmt.Errorf("date: %v", err)
+		}
+	}
+
+	err = config.Categories.Validate()
+	if err != nil {
+		return fmt.Errorf("categories: %v", err)
+	}
+
+	return nil
+}
+
+// EventDate returns the parsed event date, error if it is not set.
+func (config *Config) EventDate() (date time.Time, err error) {
+	if len(config.Date) == 0 {
+		return date, fmt.Errorf("event date is not set")
+	}
+	return category.ParseDate(config.Date)
+}
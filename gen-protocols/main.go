@@ -1,17 +1,12 @@
 package main
 
 import (
-	"encoding/csv"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
-	"strings"
-)
 
-const (
-	fakeLowRating = 1_000_000
+	"github.com/ivanzoid/race-numbers/participant"
 )
 
 // ---------------------------------------------------------------------------
@@ -25,157 +20,68 @@ func dlog(format string, args ...interface{}) {
 
 // ---------------------------------------------------------------------------
 
-type User struct {
-	firstName   string
-	lastName    string
-	name        string
-	team        string
-	rating      int64
-	paid        bool
-	startNumber int64
-	surName     string
-	phone       string
-	category    string
-	finishTime  string
-}
+func finishedUsersFromCsvFile(csvFilePath string) (users []participant.Participant, err error) {
 
-func readCsvFile(csvFilePath string) (records [][]string, err error) {
-	file, err := os.Open(csvFilePath)
+	users, err = participant.ReadFile(csvFilePath, participant.ResultsColumns)
 	if err != nil {
 		return nil, err
 	}
 
-	defer file.Close()
-
-	csvReader := csv.NewReader(file)
-	//csvReader.Comma = ';'
-
-	records, err = csvReader.ReadAll()
-
-	return
-}
-
-func csvRecordsToMap(records [][]string) (result []map[string]string) {
-
-	if len(records) == 0 {
-		return nil
-	}
-
-	result = make([]map[string]string, 0, len(records))
-
-	var header []string
-
-	for line, record := range records {
-		if line == 0 {
-			header = record
-			continue
-		}
-
-		recordMap := make(map[string]string)
-
-		for col, value := range record {
-			if col >= len(header) {
-				break
-			}
-			colName := header[col]
-			recordMap[colName] = value
-		}
-
-		result = append(result, recordMap)
-	}
-
-	return
-}
-
-func finishedUsersFromCsvFile(csvFilePath string) (users []User, err error) {
-
-	records, err := readCsvFile(csvFilePath)
-	if err != nil {
-		return nil, err
-	}
-
-	mapRecords := csvRecordsToMap(records)
-
-	users = make([]User, 0, len(records))
-
-	for _, record := range mapRecords {
-		var user User
-
-		user.startNumber, _ = strconv.ParseInt(record["number"], 10, 64)
-		user.category = record["category"]
-		user.finishTime = record["time"]
-
-		dlog("Finished user: %v, %v, %v", user.startNumber, user.category, user.finishTime)
+	for _, user := range users {
+		dlog("Finished user: %v, %v, %v", user.StartNumber, user.Category, user.FinishTime)
 	}
 
 	return
 }
 
-func participantsUsersFromCsvFile(csvFilePath string) (users []User, err error) {
+func participantsUsersFromCsvFile(csvFilePath string) (users []participant.Participant, err error) {
 
-	records, err := readCsvFile(csvFilePath)
+	users, err = participant.ReadFile(csvFilePath, participant.RatedColumns)
 	if err != nil {
 		return nil, err
 	}
 
-	mapRecords := csvRecordsToMap(records)
-
-	users = make([]User, 0, len(records))
-
-	for _, record := range mapRecords {
-		var user User
-
-		user.paid = len(record["Оплата_"]) != 0
-		user.firstName = strings.TrimSpace(record["Имя"])
-		user.lastName = strings.TrimSpace(record["Фамилия"])
-		user.name = fmt.Sprintf("%v %v", user.lastName, user.firstName)
-		user.team = strings.TrimSpace(record["Клуб/команда"])
-		user.rating = fakeLowRating
-
-		category := strings.TrimSpace(record["Категория"])
-		categoryFields := strings.Fields(category)
-		if len(categoryFields) > 0 {
-			user.category = categoryFields[0]
-		}
-
-		user.surName = strings.TrimSpace(record["Отчество"])
-		user.phone = strings.TrimSpace(record["Телефон"])
-
-		users = append(users, user)
-
-		dlog("Participant: %v, %v", user.name, user.team)
+	for _, user := range users {
+		dlog("Participant: %v, %v, %v", user.StartNumber, user.Name(), user.Team)
 	}
 
 	return
 }
 
-func usersMap(users []User) (result map[string]User) {
-	result = make(map[string]User, 0)
+func usersByNumber(users []participant.Participant) (result map[int64]participant.Participant) {
+	result = make(map[int64]participant.Participant, len(users))
 	for _, user := range users {
-		result[user.name] = user
+		if user.StartNumber != 0 && len(user.Name()) != 0 {
+			result[user.StartNumber] = user
+		}
 	}
 	return
 }
 
 var (
 	participantsFileName = ""
-	ratingFileName       = ""
-	dumpNumbers          = false
+	resultsFileName      = ""
+	order                = ""
 )
 
 func main() {
 
-	flag.StringVar(&participantsFileName, "p", "", "Participants csv file")
-	flag.StringVar(&ratingFileName, "r", "", "Results csv file")
+	flag.StringVar(&participantsFileName, "p", "", "Participants with start numbers csv file (participants_rated.csv)")
+	flag.StringVar(&resultsFileName, "r", "", "Results csv file: number, category, time (h:mm:ss, DNF, DNS, DSQ)")
+	flag.StringVar(&order, "by", byOverall, fmt.Sprintf("Protocol order, gaps are computed inside of it: %v or %v", byOverall, byCategory))
 
 	flag.Parse()
 
-	if len(participantsFileName) == 0 || len(ratingFileName) == 0 {
+	if len(participantsFileName) == 0 || len(resultsFileName) == 0 {
 		flag.Usage()
 		return
 	}
 
-	finishedUsers, err := finishedUsersFromCsvFile(ratingFileName)
+	if order != byOverall && order != byCategory {
+		log.Fatalf("Unknown order %q, expected %v or %v", order, byOverall, byCategory)
+	}
+
+	finishedUsers, err := finishedUsersFromCsvFile(resultsFileName)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -185,39 +91,59 @@ func main() {
 		log.Fatal(err)
 	}
 
-	usersMap := usersMap(participants)
-	resultUsers := make([]User, len(finishedUsers))
-	copy(resultUsers, finishedUsers)
+	usersMap := usersByNumber(participants)
+	results := make([]*result, 0, len(finishedUsers))
+	seen := make(map[int64]int, len(finishedUsers))
+
+	var errs participant.Errors
 
 	for i, finishedUser := range finishedUsers {
-		user, ok := usersMap[finishedUser.name]
+		row := i + 2
+
+		if finishedUser.StartNumber == 0 {
+			errs = append(errs, &participant.Error{Row: row, Column: "number", Err: fmt.Errorf("empty number")})
+			continue
+		}
+		if other, ok := seen[finishedUser.StartNumber]; ok {
+			errs = append(errs, &participant.Error{Row: row, Column: "number", Err: fmt.Errorf("number %v is already in row %v", finishedUser.StartNumber, other)})
+			continue
+		}
+		seen[finishedUser.StartNumber] = row
+
+		finishTime, status, err := parseFinishTime(finishedUser.FinishTime)
+		if err != nil {
+			errs = append(errs, &participant.Error{Row: row, Column: "time", Err: err})
+			continue
+		}
+
+		user, ok := usersMap[finishedUser.StartNumber]
 		if ok {
-			finishedUser.firstName = user.firstName
-			finishedUser.lastName = user.lastName
-			finishedUser.team = user.team
-			finishedUser.category = user.category
+			if len(user.Category) == 0 {
+				user.Category = finishedUser.Category
+			}
+		} else {
+			dlog("Warning: number %v in row %v has no participant", finishedUser.StartNumber, row)
+			user = finishedUser
 		}
-		resultUsers[i] = user
-	}
 
-	for _, user := range resultUsers {
-		dlog("Result user: %v %v, %v, %v", user.lastName, user.firstName, user.category, user.finishTime)
+		results = append(results, &result{user: user, finishTime: finishTime, status: status})
 	}
 
-	writer := csv.NewWriter(os.Stdout)
-	defer writer.Flush()
-
-	writer.Write([]string{"Фамилия Имя", "Команда", "Категория", "Стартовый номер", "Время"})
+	if len(errs) != 0 {
+		log.Fatalf("%v:\n%v", resultsFileName, errs)
+	}
 
-	for _, resultUser := range resultUsers {
+	computeResults(results)
+	if order == byCategory {
+		sortByCategory(results)
+	}
 
-		lineArray := make([]string, 0)
-		lineArray = append(lineArray, fmt.Sprintf("%v %v", resultUser.lastName, resultUser.firstName))
-		lineArray = append(lineArray, resultUser.team)
-		lineArray = append(lineArray, resultUser.category)
-		lineArray = append(lineArray, fmt.Sprintf("%v", resultUser.startNumber))
-		lineArray = append(lineArray, resultUser.finishTime)
+	for _, r := range results {
+		dlog("Result: %v %v %v, %v, %v", r.place, r.user.StartNumber, r.user.Name(), r.user.Category, r.finishTime)
+	}
 
-		writer.Write(lineArray)
+	err = writeProtocol(os.Stdout, results, order)
+	if err != nil {
+		log.Fatal(err)
 	}
 }
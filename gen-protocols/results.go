@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+// Statuses of riders without a finish time
+var statuses = []string{"DNF", "DNS", "DSQ"}
+
+// parseFinishTime parses "h:mm:ss", "mm:ss" with optional fraction "h:mm:ss.s".
+// Status is set instead of the time for DNF, DNS and DSQ, empty time is DNF.
+func parseFinishTime(value string) (finishTime time.Duration, status string, err error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return 0, "DNF", nil
+	}
+	for _, s := range statuses {
+		if strings.EqualFold(value, s) {
+			return 0, s, nil
+		}
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, "", fmt.Errorf("invalid time %q, expected h:mm:ss", value)
+	}
+
+	seconds, err := strconv.ParseFloat(strings.Replace(parts[len(parts)-1], ",", ".", 1), 64)
+	if err != nil || seconds < 0 || seconds >= 60 {
+		return 0, "", fmt.Errorf("invalid seconds in time %q", value)
+	}
+	finishTime = time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+
+	multiplier := time.Minute
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 || (i != 0 && n >= 60) {
+			return 0, "", fmt.Errorf("invalid time %q", value)
+		}
+		finishTime += time.Duration(n) * multiplier
+		multiplier *= 60
+	}
+
+	return finishTime, "", nil
+}
+
+// formatDuration formats as h:mm:ss if withHours is set or the duration is an
+// hour or more, otherwise as m:ss. Tenths are shown if withTenths is set.
+func formatDuration(d time.Duration, withTenths, withHours bool) string {
+	d = roundDuration(d, withTenths)
+
+	tenths := ""
+	if withTenths {
+		tenths = fmt.Sprintf(".%d", d%time.Second/(time.Second/10))
+	}
+
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	seconds := int(d % time.Minute / time.Second)
+
+	if hours != 0 || withHours {
+		return fmt.Sprintf("%d:%02d:%02d%v", hours, minutes, seconds, tenths)
+	}
+	return fmt.Sprintf("%d:%02d%v", minutes, seconds, tenths)
+}
+
+// roundDuration rounds to tenths or to seconds as the duration is shown.
+func roundDuration(d time.Duration, withTenths bool) time.Duration {
+	if withTenths {
+		return d.Round(time.Second / 10)
+	}
+	return d.Round(time.Second)
+}
+
+// result of a single rider.
+type result struct {
+	user       participant.Participant
+	finishTime time.Duration
+	status     string // empty for finishers
+
+	place       int // 0 for non-finishers
+	gapLeader   time.Duration
+	gapPrevious time.Duration
+
+	categoryPlace       int
+	categoryGapLeader   time.Duration
+	categoryGapPrevious time.Duration
+}
+
+func (r *result) finished() bool {
+	return len(r.status) == 0
+}
+
+// sortResults orders finishers by time, then by number, non-finishers go last by status and number.
+func sortResults(results []*result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		ri, rj := results[i], results[j]
+		if ri.finished() != rj.finished() {
+			return ri.finished()
+		}
+		if ri.finished() && ri.finishTime != rj.finishTime {
+			return ri.finishTime < rj.finishTime
+		}
+		if ri.status != rj.status {
+			return ri.status < rj.status
+		}
+		return ri.user.StartNumber < rj.user.StartNumber
+	})
+}
+
+// rank sets places and gaps of sorted results, riders with equal time share the
+// place and the next place is skipped: 1, 2, 2, 4.
+func rank(results []*result, set func(r *result, place int, gapLeader, gapPrevious time.Duration)) {
+	place := 0
+	for i, r := range results {
+		if !r.finished() {
+			continue
+		}
+		var gapLeader, gapPrevious time.Duration
+		if i != 0 {
+			gapLeader = r.finishTime - results[0].finishTime
+			gapPrevious = r.finishTime - results[i-1].finishTime
+		}
+		if i == 0 || gapPrevious != 0 {
+			place = i + 1
+		}
+		set(r, place, gapLeader, gapPrevious)
+	}
+}
+
+// computeResults sorts results and sets overall and category places and gaps.
+func computeResults(results []*result) {
+	sortResults(results)
+
+	rank(results, func(r *result, place int, gapLeader, gapPrevious time.Duration) {
+		r.place = place
+		r.gapLeader = gapLeader
+		r.gapPrevious = gapPrevious
+	})
+
+	byCategory := make(map[string][]*result)
+	for _, r := range results {
+		byCategory[r.user.Category] = append(byCategory[r.user.Category], r)
+	}
+
+	for _, categoryResults := range byCategory {
+		rank(categoryResults, func(r *result, place int, gapLeader, gapPrevious time.Duration) {
+			r.categoryPlace = place
+			r.categoryGapLeader = gapLeader
+			r.categoryGapPrevious = gapPrevious
+		})
+	}
+}
+
+// Protocol orders
+const (
+	byOverall  = "overall"
+	byCategory = "category"
+)
+
+var protocolHeader = []string{"Место", "Место в категории", "Номер", "Фамилия Имя", "Команда", "Категория", "Время", "Отставание от лидера", "Отставание от предыдущего"}
+
+// sortByCategory groups sorted results by category keeping the order inside
+// of a group, riders without category go last.
+func sortByCategory(results []*result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		ci, cj := results[i].user.Category, results[j].user.Category
+		if ci != cj && (len(ci) == 0 || len(cj) == 0) {
+			return len(cj) == 0
+		}
+		return ci < cj
+	})
+}
+
+// writeProtocol writes computed results. Gaps are overall or inside of the
+// category depending on the order. All times and gaps have the same format:
+// h:mm:ss if anybody rode an hour or more, tenths if any time has them.
+func writeProtocol(w io.Writer, results []*result, order string) error {
+	withTenths := false
+	for _, r := range results {
+		if r.finishTime%time.Second != 0 {
+			withTenths = true
+		}
+	}
+
+	withHours := false
+	for _, r := range results {
+		if r.finished() && roundDuration(r.finishTime, withTenths) >= time.Hour {
+			withHours = true
+		}
+	}
+
+	formatGap := func(gap time.Duration, place int) string {
+		if place == 0 || (place == 1 && gap == 0) {
+			return ""
+		}
+		return "+" + formatDuration(gap, withTenths, withHours)
+	}
+	formatPlace := func(place int) string {
+		if place == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%v", place)
+	}
+
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(protocolHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		finishTime := r.status
+		if r.finished() {
+			finishTime = formatDuration(r.finishTime, withTenths, withHours)
+		}
+
+		gapLeader, gapPrevious := r.gapLeader, r.gapPrevious
+		place := r.place
+		if order == byCategory {
+			gapLeader, gapPrevious = r.categoryGapLeader, r.categoryGapPrevious
+			place = r.categoryPlace
+		}
+
+		err = writer.Write([]string{
+			formatPlace(r.place),
+			formatPlace(r.categoryPlace),
+			r.user.Value(participant.FieldStartNumber),
+			r.user.Name(),
+			r.user.Team,
+			r.user.Category,
+			finishTime,
+			formatGap(gapLeader, place),
+			formatGap(gapPrevious, place),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+func TestParseFinishTime(t *testing.T) {
+	tests := []struct {
+		value      string
+		finishTime time.Duration
+		status     string
+		wantErr    bool
+	}{
+		{value: "1:02:03", finishTime: time.Hour + 2*time.Minute + 3*time.Second},
+		{value: "59:59.5", finishTime: 59*time.Minute + 59*time.Second + 500*time.Millisecond},
+		{value: "2:05:07,25", finishTime: 2*time.Hour + 5*time.Minute + 7*time.Second + 250*time.Millisecond},
+		{value: "75:00", finishTime: 75 * time.Minute},
+		{value: "", status: "DNF"},
+		{value: "dns", status: "DNS"},
+		{value: " DSQ ", status: "DSQ"},
+		{value: "1:60:00", wantErr: true},
+		{value: "1:00:60", wantErr: true},
+		{value: "3600", wantErr: true},
+		{value: "1:2:3:4", wantErr: true},
+		{value: "fast", wantErr: true},
+	}
+
+	for _, test := range tests {
+		finishTime, status, err := parseFinishTime(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: error %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if finishTime != test.finishTime || status != test.status {
+			t.Errorf("%q: %v %q, want %v %q", test.value, finishTime, status, test.finishTime, test.status)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d          time.Duration
+		withTenths bool
+		withHours  bool
+		want       string
+	}{
+		{d: 59*time.Minute + 59*time.Second, want: "59:59"},
+		{d: 59*time.Minute + 59*time.Second + 500*time.Millisecond, withTenths: true, want: "59:59.5"},
+		{d: 59*time.Minute + 59*time.Second + 500*time.Millisecond, withTenths: true, withHours: true, want: "0:59:59.5"},
+		{d: 59*time.Minute + 59*time.Second + 960*time.Millisecond, withTenths: true, want: "1:00:00.0"},
+		{d: time.Hour + 2*time.Minute + 3*time.Second, want: "1:02:03"},
+		{d: 3 * time.Second, withHours: true, want: "0:00:03"},
+	}
+
+	for _, test := range tests {
+		if got := formatDuration(test.d, test.withTenths, test.withHours); got != test.want {
+			t.Errorf("%v: %q, want %q", test.d, got, test.want)
+		}
+	}
+}
+
+func testResult(number int64, category, finishTime string) *result {
+	user := participant.New()
+	user.StartNumber = number
+	user.Category = category
+	r := &result{user: user}
+	r.finishTime, r.status, _ = parseFinishTime(finishTime)
+	return r
+}
+
+func TestComputeResults(t *testing.T) {
+	results := []*result{
+		testResult(1, "М", "1:00:10"),
+		testResult(2, "М", "1:00:00"),
+		testResult(3, "Ж", "1:00:05"),
+		testResult(4, "М", "DNF"),
+		testResult(5, "Ж", "1:00:05"),
+		testResult(6, "М", "1:00:20"),
+	}
+
+	computeResults(results)
+
+	type place struct {
+		number        int64
+		place         int
+		categoryPlace int
+		gapPrevious   time.Duration
+	}
+	var got []place
+	for _, r := range results {
+		got = append(got, place{r.user.StartNumber, r.place, r.categoryPlace, r.gapPrevious})
+	}
+
+	// Riders with equal time share the place and the next one is skipped
+	want := []place{
+		{2, 1, 1, 0},
+		{3, 2, 1, 5 * time.Second},
+		{5, 2, 1, 0},
+		{1, 4, 2, 5 * time.Second},
+		{6, 5, 3, 10 * time.Second},
+		{4, 0, 0, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("places %v, want %v", got, want)
+	}
+}
+
+func TestWriteProtocolTimeFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		times []string
+		want  []string // time and gap to the leader of every row
+	}{
+		{
+			name:  "under an hour",
+			times: []string{"58:03", "59:59"},
+			want:  []string{"58:03", "", "59:59", "+1:56"},
+		},
+		{
+			name:  "hours for everybody if anybody rode an hour",
+			times: []string{"59:59.5", "1:02:03"},
+			want:  []string{"0:59:59.5", "", "1:02:03.0", "+0:02:03.5"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var results []*result
+			for i, finishTime := range test.times {
+				results = append(results, testResult(int64(i+1), "", finishTime))
+			}
+			computeResults(results)
+
+			var buffer bytes.Buffer
+			if err := writeProtocol(&buffer, results, byOverall); err != nil {
+				t.Fatal(err)
+			}
+			records, err := csv.NewReader(&buffer).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, record := range records[1:] {
+				got = append(got, record[6], record[7])
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("times %q, want %q", got, test.want)
+			}
+		})
+	}
+}
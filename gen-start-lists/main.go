@@ -1,18 +1,13 @@
 package main
 
 import (
-	"encoding/csv"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"sort"
-	"strconv"
-	"strings"
-)
 
-const (
-	fakeLowRating = 1_000_000
+	"github.com/ivanzoid/race-numbers/event"
+	"github.com/ivanzoid/race-numbers/participant"
 )
 
 // ---------------------------------------------------------------------------
@@ -26,250 +21,119 @@ func dlog(format string, args ...interface{}) {
 
 // ---------------------------------------------------------------------------
 
-type User struct {
-	firstName string
-	lastName  string
-	name      string
-	team      string
-	// points       int64
-	rating      int64
-	paid        bool
-	startNumber int64
-	surName     string
-	phone       string
-	category    string
-}
-
-func readCsvFile(csvFilePath string) (records [][]string, err error) {
-	file, err := os.Open(csvFilePath)
-	if err != nil {
-		return nil, err
-	}
-
-	defer file.Close()
-
-	csvReader := csv.NewReader(file)
-	//csvReader.Comma = ';'
-
-	records, err = csvReader.ReadAll()
-
-	return
+var startListColumns = participant.Columns{
+	{Field: participant.FieldLastName, Header: "lastName"},
+	{Field: participant.FieldFirstName, Header: "firstName"},
+	{Field: participant.FieldTeam, Header: "team"},
+	{Field: participant.FieldCategory, Header: "category"},
+	{Field: participant.FieldStartNumber, Header: "number"},
 }
 
-func csvRecordsToMap(records [][]string) (result []map[string]string) {
-
-	if len(records) == 0 {
-		return nil
-	}
-
-	result = make([]map[string]string, 0, len(records))
-
-	var header []string
-
-	for line, record := range records {
-		if line == 0 {
-			header = record
-			continue
-		}
+func ratedUsersFromCsvFile(csvFilePath string) (users []participant.Participant, err error) {
 
-		recordMap := make(map[string]string)
-
-		for col, value := range record {
-			if col >= len(header) {
-				break
-			}
-			colName := header[col]
-			recordMap[colName] = value
-		}
-
-		result = append(result, recordMap)
-	}
-
-	return
-}
-
-func ratedUsersFromCsvFile(csvFilePath string) (users []User, err error) {
-
-	records, err := readCsvFile(csvFilePath)
+	users, err = participant.ReadFile(csvFilePath, participant.RatedColumns)
 	if err != nil {
 		return nil, err
 	}
 
-	mapRecords := csvRecordsToMap(records)
-
-	users = make([]User, 0, len(records))
-
-	for _, record := range mapRecords {
-		var user User
-
-		firstName := strings.TrimSpace(record["firstname"])
-		lastName := strings.TrimSpace(record["lastname"])
-
-		user.name = fmt.Sprintf("%v %v", lastName, firstName)
-		//user.points, _ = strconv.ParseInt(record["pts"], 10, 64)
-		rating, err := strconv.ParseInt(record["number"], 10, 64)
-		if err == nil {
-			user.rating = rating
-		} else {
-			user.rating = fakeLowRating
-		}
-		users = append(users, user)
-
-		dlog("Rated user: %v, %v", user.name, user.rating)
+	for _, user := range users {
+		dlog("Rated user: %v, %v", user.Name(), user.Value(participant.FieldStartNumber))
 	}
 
 	return
 }
 
-func participantsUsersFromCsvFile(csvFilePath string) (users []User, err error) {
+func participantsUsersFromCsvFile(csvFilePath string, columns participant.Columns) (users []participant.Participant, err error) {
 
-	records, err := readCsvFile(csvFilePath)
+	allUsers, err := participant.ReadFile(csvFilePath, columns.Require(participant.FieldPaid))
 	if err != nil {
 		return nil, err
 	}
 
-	mapRecords := csvRecordsToMap(records)
-
-	users = make([]User, 0, len(records))
-
-	for _, record := range mapRecords {
-		var user User
+	users = make([]participant.Participant, 0, len(allUsers))
 
-		user.paid = len(record["Оплата_"]) != 0
-		if !user.paid {
+	for _, user := range allUsers {
+		if !user.Paid {
 			continue
 		}
-		user.firstName = strings.TrimSpace(record["Имя"])
-		user.lastName = strings.TrimSpace(record["Фамилия"])
-		user.name = fmt.Sprintf("%v %v", user.lastName, user.firstName)
-		user.team = strings.TrimSpace(record["Клуб/команда"])
-		user.rating = fakeLowRating
-
-		category := strings.TrimSpace(record["Категория"])
-		categoryFields := strings.Fields(category)
-		if len(categoryFields) > 0 {
-			user.category = categoryFields[0]
-		}
-
-		user.surName = strings.TrimSpace(record["Отчество"])
-		user.phone = strings.TrimSpace(record["Телефон"])
 
 		users = append(users, user)
 
-		dlog("Participant: %v, %v", user.name, user.team)
+		dlog("Participant: %v, %v", user.Name(), user.Team)
 	}
 
 	return
 }
 
-func ratedUsersMap(users []User) (result map[string]User) {
-	result = make(map[string]User, 0)
+// ratedUsersMap maps normalized names to riders with numbers, namesakes are
+// queued in file order. Numbers held for riders who left are skipped.
+func ratedUsersMap(users []participant.Participant) (result map[string][]participant.Participant) {
+	result = make(map[string][]participant.Participant, len(users))
 	for _, user := range users {
-		result[user.name] = user
+		if user.StartNumber == 0 || len(user.Name()) == 0 || user.Held {
+			continue
+		}
+		name := participant.NormalizeName(user.Name())
+		result[name] = append(result[name], user)
 	}
 	return
 }
 
 var (
 	participantsFileName = ""
-	ratingFileName       = ""
-	dumpNumbers          = false
+	eventFileName        = ""
+	ratedFileName        = ""
 )
 
 func main() {
 
 	flag.StringVar(&participantsFileName, "p", "", "Participants csv file")
-	flag.StringVar(&ratingFileName, "r", "", "Rating csv file")
+	flag.StringVar(&eventFileName, "event", "", "Event config json file (registration columns etc)")
+	flag.StringVar(&ratedFileName, "rated", "", "participants_rated.csv of rate-participants, numbers are taken from it")
 
 	flag.Parse()
 
-	if len(participantsFileName) == 0 || len(ratingFileName) == 0 {
+	if len(participantsFileName) == 0 || len(ratedFileName) == 0 {
 		flag.Usage()
 		return
 	}
 
-	ratedUsers, err := ratedUsersFromCsvFile(ratingFileName)
+	config, err := event.ReadConfigFile(eventFileName)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	participants, err := participantsUsersFromCsvFile(participantsFileName)
+	ratedUsers, err := ratedUsersFromCsvFile(ratedFileName)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	ratedUsersMap := ratedUsersMap(ratedUsers)
-	allUsers := make([]User, len(participants))
-	copy(allUsers, participants)
-
-	for i, user := range participants {
-		ratedUser, ok := ratedUsersMap[user.name]
-		if ok {
-			//user.points = ratedUser.points
-			user.rating = ratedUser.rating
-		}
-		allUsers[i] = user
-	}
-
-	for _, user := range allUsers {
-		dlog("User: %v, rating:%v", user.name, user.rating)
+	participants, err := participantsUsersFromCsvFile(participantsFileName, config.Columns)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	sortedUsers := make([]User, len(allUsers))
-	copy(sortedUsers, allUsers)
+	// Numbers are assigned once by rate-participants (with rating matches,
+	// overrides and locking), so start lists always agree with printed bibs
+	ratedUsersMap := ratedUsersMap(ratedUsers)
+	startListUsers := make([]participant.Participant, 0, len(participants))
 
-	sort.Slice(sortedUsers, func(index1, index2 int) bool {
-		user1 := sortedUsers[index1]
-		user2 := sortedUsers[index2]
-		if user2.rating > user1.rating {
-			return true
-		} else if user2.rating < user1.rating {
-			return false
+	for _, user := range participants {
+		name := participant.NormalizeName(user.Name())
+		queue := ratedUsersMap[name]
+		if len(queue) == 0 {
+			dlog("Warning: %q has no number in %v, run rate-participants again", user.Name(), ratedFileName)
 		} else {
-			return strings.Compare(user1.name, user2.name) < 0
+			user.StartNumber = queue[0].StartNumber
+			user.NumberText = queue[0].NumberText
+			ratedUsersMap[name] = queue[1:]
 		}
-	})
 
-	for _, user := range sortedUsers {
-		dlog("Sorted user: %v, rating:%v", user.name, user.rating)
+		startListUsers = append(startListUsers, user)
 	}
 
-	allUsersMap := make(map[string]User)
-
-	number := 1
-
-	for i := 0; i < len(sortedUsers); i++ {
-
-		user := sortedUsers[i]
-
-		if user.paid {
-			user.startNumber = int64(number)
-			number += 1
-		}
-
-		allUsersMap[user.name] = user
-		sortedUsers[i] = user
-	}
-
-	writer := csv.NewWriter(os.Stdout)
-	defer writer.Flush()
-
-	writer.Write([]string{"lastName", "firstName", "team", "category", "number"})
-
-	for _, participantUser := range participants {
-
-		user, ok := allUsersMap[participantUser.name]
-		if !ok {
-			continue
-		}
-
-		lineArray := make([]string, 0)
-		lineArray = append(lineArray, user.lastName)
-		lineArray = append(lineArray, user.firstName)
-		lineArray = append(lineArray, user.team)
-		lineArray = append(lineArray, user.category)
-		lineArray = append(lineArray, fmt.Sprintf("%v", user.startNumber))
-
-		writer.Write(lineArray)
+	err = participant.Write(os.Stdout, startListUsers, startListColumns)
+	if err != nil {
+		log.Fatal(err)
 	}
 }
@@ -3,8 +3,11 @@ module github.com/ivanzoid/race-numbers
 go 1.14
 
 require (
+	github.com/boombuler/barcode v1.0.0
 	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/phpdave11/gofpdi v1.0.7 // indirect
+	golang.org/x/image v0.0.0-20190910094157-69e4b8554b2a
 	golang.org/x/net v0.0.0-20200813134508-3edf25e44fcc
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	google.golang.org/api v0.30.0
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ivanzoid/race-numbers/event"
+	"github.com/ivanzoid/race-numbers/numbering"
+)
+
+func dlog(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, args...)
+	fmt.Fprintf(os.Stderr, "\n")
+}
+
+var bibFileNameRegexp = regexp.MustCompile(`^(\d+)\.pdf$`)
+
+// renderedNumbers lists numbers of bibs rendered to the dir by render-numbers.
+func renderedNumbers(dir string) (files map[int64]string, err error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files = make(map[int64]string)
+
+	for _, entry := range entries {
+		match := bibFileNameRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		number, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || number == 0 {
+			continue
+		}
+		files[number] = filepath.Join(dir, entry.Name())
+	}
+
+	return files, nil
+}
+
+var (
+	inDir          = ""
+	eventFileName  = ""
+	outFileName    = ""
+	numbersString  = ""
+	paper          = ""
+	landscape      = false
+	nup            = ""
+	margin         = 0.0
+	gutter         = 0.0
+	rotationString = ""
+	cropMarks      = false
+)
+
+func main() {
+
+	flag.StringVar(&inDir, "in", "../_out", "Dir with rendered bibs (001.pdf, 002.pdf, ...)")
+	flag.StringVar(&eventFileName, "event", "", "Event config json file (excluded numbers)")
+	flag.StringVar(&outFileName, "o", "imposed.pdf", "Output pdf file")
+	flag.StringVar(&numbersString, "numbers", "", "Numbers to impose, like 1-20,45,100- (default is all rendered bibs)")
+	flag.StringVar(&paper, "paper", "A4", "Sheet size: A4 or A3")
+	flag.BoolVar(&landscape, "landscape", false, "Landscape sheets")
+	flag.StringVar(&nup, "nup", "1x2", "Bibs per sheet, columns x rows")
+	flag.Float64Var(&margin, "margin", 5, "Sheet margin, mm")
+	flag.Float64Var(&gutter, "gutter", 5, "Space between bibs, mm")
+	flag.StringVar(&rotationString, "rotate", "0,180", "Rotation of bibs in degrees, cycled over the slots of a sheet")
+	flag.BoolVar(&cropMarks, "crop", false, "Draw crop marks")
+
+	flag.Parse()
+
+	paperSize, ok := paperSizes[strings.ToUpper(paper)]
+	if !ok {
+		log.Fatalf("Unknown paper size %q", paper)
+	}
+	if landscape {
+		paperSize.Wd, paperSize.Ht = paperSize.Ht, paperSize.Wd
+	}
+
+	cols, rows, err := parseNup(nup)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rotations, err := parseRotations(rotationString)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := event.ReadConfigFile(eventFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	files, err := renderedNumbers(inDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var numbers []int64
+
+	if len(numbersString) == 0 {
+		for number := range files {
+			numbers = append(numbers, number)
+		}
+		sort.Slice(numbers, func(i, j int) bool {
+			return numbers[i] < numbers[j]
+		})
+	} else {
+		ranges, err := numbering.ParseRanges(numbersString)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var max int64
+		for number := range files {
+			if number > max {
+				max = number
+			}
+		}
+
+		missing := make([]string, 0)
+		for _, number := range ranges.Numbers(max) {
+			if _, ok := files[number]; !ok {
+				// Excluded numbers are not rendered unless somebody has them
+				if config.Numbering.Exclude.Contains(number) {
+					continue
+				}
+				missing = append(missing, fmt.Sprintf("%v", number))
+			}
+			numbers = append(numbers, number)
+		}
+		if len(missing) != 0 {
+			log.Fatalf("No rendered bibs in %v for numbers: %v", inDir, strings.Join(missing, ", "))
+		}
+	}
+
+	if len(numbers) == 0 {
+		log.Fatalf("No bibs to impose in %v", inDir)
+	}
+
+	imposer, err := newImposer(&layout{
+		paper:     paperSize,
+		cols:      cols,
+		rows:      rows,
+		margin:    margin,
+		gutter:    gutter,
+		rotations: rotations,
+		cropMarks: cropMarks,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, number := range numbers {
+		err = imposer.add(files[number])
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(imposer.scaled) != 0 {
+		dlog("Warning: %v bibs don't fit the %vx%v grid and were scaled down, e.g. %v",
+			len(imposer.scaled), cols, rows, imposer.scaled[0])
+	}
+
+	err = imposer.outputFileAndClose(outFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dlog("Imposed %v numbers on %v sheets to %v", len(numbers), imposer.sheets, outFileName)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/jung-kurt/gofpdf/contrib/gofpdi"
+
+	"github.com/ivanzoid/race-numbers/bib"
+)
+
+const (
+	cropMarkOffset = 1.0  // mm between the bib edge and a crop mark
+	cropMarkLength = 4.0  // mm
+	cropMarkWidth  = 0.1  // mm
+	sizeTolerance  = 0.01 // mm, page sizes are stored in points and don't convert back exactly
+)
+
+// paperSizes lists sheet sizes in mm, portrait.
+var paperSizes = map[string]gofpdf.SizeType{
+	"A3": {Wd: 297, Ht: 420},
+	"A4": {Wd: 210, Ht: 297},
+}
+
+// layout describes how bibs are placed on a sheet, sizes are in mm.
+type layout struct {
+	paper     gofpdf.SizeType
+	cols      int
+	rows      int
+	margin    float64
+	gutter    float64
+	rotations []float64 // cycled over the slots of a sheet
+	cropMarks bool
+}
+
+// parseNup parses grid like "2x3" (columns x rows).
+func parseNup(s string) (cols, rows int, err error) {
+	parts := strings.Split(strings.ToLower(s), "x")
+	if len(parts) == 2 {
+		cols, err = strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err == nil {
+			rows, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+		}
+	}
+	if len(parts) != 2 || err != nil || cols < 1 || rows < 1 {
+		return 0, 0, fmt.Errorf("invalid grid %q, expected columns x rows like 1x2", s)
+	}
+	return cols, rows, nil
+}
+
+// parseRotations parses comma separated angles like "0,180".
+func parseRotations(s string) (rotations []float64, err error) {
+	for _, item := range strings.Split(s, ",") {
+		angle, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || angle%90 != 0 {
+			return nil, fmt.Errorf("invalid rotation %q, expected multiples of 90 like 0,180", item)
+		}
+		angle %= 360
+		if angle < 0 {
+			angle += 360
+		}
+		rotations = append(rotations, float64(angle))
+	}
+	return rotations, nil
+}
+
+func (l *layout) slots() int {
+	return l.cols * l.rows
+}
+
+func (l *layout) cellSize() (w, h float64) {
+	w = (l.paper.Wd - 2*l.margin - float64(l.cols-1)*l.gutter) / float64(l.cols)
+	h = (l.paper.Ht - 2*l.margin - float64(l.rows-1)*l.gutter) / float64(l.rows)
+	return w, h
+}
+
+// imposer writes bibs to sheets.
+type imposer struct {
+	layout   *layout
+	pdf      *gofpdf.Fpdf
+	importer *gofpdi.Importer
+	slot     int
+	sheets   int
+	scaled   []string
+}
+
+func newImposer(l *layout) (im *imposer, err error) {
+	cellWidth, cellHeight := l.cellSize()
+	if cellWidth <= 0 || cellHeight <= 0 {
+		return nil, fmt.Errorf("%vx%v grid with margin %vmm and gutter %vmm doesn't fit %vx%vmm sheet",
+			l.cols, l.rows, l.margin, l.gutter, l.paper.Wd, l.paper.Ht)
+	}
+
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    l.paper,
+	})
+
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+
+	im = &imposer{
+		layout:   l,
+		pdf:      pdf,
+		importer: gofpdi.NewImporter(),
+	}
+
+	return im, nil
+}
+
+// add places the first page of the bib pdf to the next free slot.
+func (im *imposer) add(bibFileName string) (err error) {
+	page, err := bib.ImportPage(im.pdf, im.importer, bibFileName)
+	if err != nil {
+		return err
+	}
+
+	l := im.layout
+
+	if im.slot%l.slots() == 0 {
+		im.pdf.AddPage()
+		im.sheets++
+	}
+
+	slot := im.slot % l.slots()
+	im.slot++
+
+	cellWidth, cellHeight := l.cellSize()
+	col := slot % l.cols
+	row := slot / l.cols
+
+	// Center of the cell
+	cx := l.margin + float64(col)*(cellWidth+l.gutter) + cellWidth/2
+	cy := l.margin + float64(row)*(cellHeight+l.gutter) + cellHeight/2
+
+	angle := l.rotations[slot%len(l.rotations)]
+
+	// Size the bib takes on the sheet after rotation
+	w, h := page.Width, page.Height
+	if angle == 90 || angle == 270 {
+		w, h = h, w
+	}
+
+	// Bibs are printed in their real size, only ones too big for the cell are scaled down
+	scale := 1.0
+	if w > cellWidth+sizeTolerance || h > cellHeight+sizeTolerance {
+		scale = math.Min(cellWidth/w, cellHeight/h)
+		im.scaled = append(im.scaled, bibFileName)
+	}
+	w *= scale
+	h *= scale
+
+	pageWidth := page.Width * scale
+	pageHeight := page.Height * scale
+
+	im.pdf.TransformBegin()
+	im.pdf.TransformRotate(angle, cx, cy)
+	im.importer.UseImportedTemplate(im.pdf, page.Template, cx-pageWidth/2, cy-pageHeight/2, pageWidth, pageHeight)
+	im.pdf.TransformEnd()
+
+	if l.cropMarks {
+		im.drawCropMarks(cx-w/2, cy-h/2, cx+w/2, cy+h/2)
+	}
+
+	return im.pdf.Error()
+}
+
+// drawCropMarks draws short lines continuing the bib edges outside of its corners.
+func (im *imposer) drawCropMarks(x0, y0, x1, y1 float64) {
+	pdf := im.pdf
+
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(cropMarkWidth)
+
+	for _, x := range []float64{x0, x1} {
+		pdf.Line(x, y0-cropMarkOffset, x, y0-cropMarkOffset-cropMarkLength)
+		pdf.Line(x, y1+cropMarkOffset, x, y1+cropMarkOffset+cropMarkLength)
+	}
+	for _, y := range []float64{y0, y1} {
+		pdf.Line(x0-cropMarkOffset, y, x0-cropMarkOffset-cropMarkLength, y)
+		pdf.Line(x1+cropMarkOffset, y, x1+cropMarkOffset+cropMarkLength, y)
+	}
+}
+
+func (im *imposer) outputFileAndClose(fileName string) error {
+	return im.pdf.OutputFileAndClose(fileName)
+}
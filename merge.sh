@@ -1,34 +1,13 @@
 #!/bin/bash
 
-program_exists () {
-	type "$1" &> /dev/null ;
-}
-
 main()
 {
-    if ! program_exists pdfjam; then
-        echo "Please install pdfjam"
-        exit 2
-    fi
-
+    # Two bibs per A4 sheet, every second one upside down.
+    # Pass e.g. -numbers 1-100 to impose only some of the numbers, -crop for crop marks.
     mkdir -p _merged
-    mkdir -p _tmp
-    rm _merged/*
-    rm _tmp/*
-
-    for ((i = 1 ; i <= 100 ; i+=2 )); do
-        NUM1=$(printf '%03d' $i)
-        NUM2=$(printf '%03d' $((i+1)))
-        FILE1="${NUM1}.pdf"
-        FILE2="${NUM2}.pdf"
-        echo $FILE1 $FILE2
-
-        pdfjam --angle 180 --fitpaper true --rotateoversize true _out/$FILE2 --outfile _tmp/$FILE2
-
-        OUTFILE="${NUM1}-${NUM2}.pdf"
 
-        pdfjam --nup 1x2 _out/$FILE1 _tmp/$FILE2 --outfile _merged/$OUTFILE
-    done
+    cd impose-numbers
+    go run . -event ../_data/event.json -in ../_out -o ../_merged/numbers.pdf -paper A4 -nup 1x2 -rotate 0,180 "$@"
 }
 
 main "$@"
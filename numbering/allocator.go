@@ -0,0 +1,366 @@
+package numbering
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ivanzoid/race-numbers/category"
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+// Rules configure start number allocation of an event.
+//
+//	"numbering": {
+//	  "reserved": "1,100-110",
+//	  "exclude": "13",
+//	  "spares": "290-299",
+//	  "width": 3,
+//	  "blocks": [{"categories": ["Ж*"], "start": 200, "size": 100, "prefix": "Ж"}]
+//	}
+type Rules struct {
+	// Reserved numbers are never allocated automatically, they can only be
+	// taken as personal numbers (last year's champion, sponsors).
+	Reserved Ranges `json:"reserved,omitempty"`
+
+	// Exclude are numbers which are never given to anyone.
+	Exclude Ranges `json:"exclude,omitempty"`
+
+	// Spares are bibs printed without names in advance. They are not given to
+	// registered participants, but to the ones registered after printing.
+	Spares Ranges `json:"spares,omitempty"`
+
+	// Format of printed numbers: zero padded to Width digits after Prefix.
+	Width  int    `json:"width,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+
+	// Blocks give categories their own number ranges. Participants of other
+	// categories get numbers from 1 skipping all blocks.
+	Blocks []Block `json:"blocks,omitempty"`
+}
+
+// Block is a range of numbers for categories matching any of the patterns.
+type Block struct {
+	// Categories are patterns of participant categories: ["М18-22"], ["Ж*"].
+	Categories category.Patterns `json:"categories"`
+	Start      int64             `json:"start"`
+	Size       int64             `json:"size"`
+
+	// Format of numbers of the block, event-wide format if not set.
+	Width  *int    `json:"width,omitempty"`
+	Prefix *string `json:"prefix,omitempty"`
+}
+
+// End is the last number of the block.
+func (block *Block) End() int64 {
+	return block.Start + block.Size - 1
+}
+
+func (block *Block) String() string {
+	return fmt.Sprintf("%v-%v (%v)", block.Start, block.End(), block.Categories)
+}
+
+func (block *Block) contains(number int64) bool {
+	return number >= block.Start && number <= block.End()
+}
+
+// Validate checks rules consistency.
+func (rules *Rules) Validate() error {
+	if !rules.Reserved.Bounded() {
+		return fmt.Errorf("reserved: open-ended range %v", rules.Reserved)
+	}
+	if !rules.Exclude.Bounded() {
+		return fmt.Errorf("exclude: open-ended range %v", rules.Exclude)
+	}
+	if !rules.Spares.Bounded() {
+		return fmt.Errorf("spares: open-ended range %v", rules.Spares)
+	}
+	for _, number := range rules.Spares.Numbers(0) {
+		if rules.Reserved.Contains(number) || rules.Exclude.Contains(number) {
+			return fmt.Errorf("spare number %v is reserved or excluded", number)
+		}
+	}
+	if rules.Width < 0 || rules.Width > maxWidth {
+		return fmt.Errorf("invalid width %v, expected 0..%v", rules.Width, maxWidth)
+	}
+	if err := validatePrefix(rules.Prefix); err != nil {
+		return err
+	}
+
+	for i := range rules.Blocks {
+		block := &rules.Blocks[i]
+		if len(block.Categories) == 0 {
+			return fmt.Errorf("block %v: no categories", i+1)
+		}
+		if err := block.Categories.Validate(); err != nil {
+			return fmt.Errorf("block %v: %v", i+1, err)
+		}
+		if block.Start <= 0 || block.Size <= 0 {
+			return fmt.Errorf("block %v: invalid start %v or size %v", i+1, block.Start, block.Size)
+		}
+		if block.Width != nil && (*block.Width < 0 || *block.Width > maxWidth) {
+			return fmt.Errorf("block %v: invalid width %v, expected 0..%v", i+1, *block.Width, maxWidth)
+		}
+		if block.Prefix != nil {
+			if err := validatePrefix(*block.Prefix); err != nil {
+				return fmt.Errorf("block %v: %v", i+1, err)
+			}
+		}
+		for j := 0; j < i; j++ {
+			other := &rules.Blocks[j]
+			if block.Start <= other.End() && other.Start <= block.End() {
+				return fmt.Errorf("block %v overlaps block %v", block, other)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePrefix rejects digits, otherwise printed numbers can't be read back:
+// prefix "1" and number 5 give "15".
+func validatePrefix(prefix string) error {
+	if strings.IndexFunc(prefix, unicode.IsDigit) >= 0 {
+		return fmt.Errorf("invalid prefix %q, digits are not allowed", prefix)
+	}
+	return nil
+}
+
+// Format makes the printed number: prefix and zero padding of the block the
+// number belongs to, or event-wide ones.
+func (rules *Rules) Format(number int64) string {
+	width := rules.Width
+	prefix := rules.Prefix
+
+	if block := rules.blockOf(number); block != nil {
+		if block.Width != nil {
+			width = *block.Width
+		}
+		if block.Prefix != nil {
+			prefix = *block.Prefix
+		}
+	}
+
+	return fmt.Sprintf("%v%0*d", prefix, width, number)
+}
+
+// block returns the first block of the category, nil if the category has none.
+func (rules *Rules) block(category string) *Block {
+	for i := range rules.Blocks {
+		if rules.Blocks[i].Categories.Match(category) {
+			return &rules.Blocks[i]
+		}
+	}
+	return nil
+}
+
+// blockOf returns the block containing the number, nil if none.
+func (rules *Rules) blockOf(number int64) *Block {
+	for i := range rules.Blocks {
+		if rules.Blocks[i].contains(number) {
+			return &rules.Blocks[i]
+		}
+	}
+	return nil
+}
+
+const maxWidth = 9
+
+// CollisionError is a number claimed by several participants.
+type CollisionError struct {
+	Number int64
+	Owner  string
+	Other  string
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("number %v of %q is also claimed by %q", e.Number, e.Owner, e.Other)
+}
+
+// Allocator hands out start numbers, keeping reserved and already taken ones.
+type Allocator struct {
+	rules  *Rules
+	owners map[int64]string
+	next   map[*Block]int64 // nil block is the common pool
+}
+
+func NewAllocator(rules *Rules) *Allocator {
+	return &Allocator{
+		rules:  rules,
+		owners: make(map[int64]string),
+		next:   make(map[*Block]int64),
+	}
+}
+
+// Owner returns who has taken the number.
+func (allocator *Allocator) Owner(number int64) (owner string, ok bool) {
+	owner, ok = allocator.owners[number]
+	return
+}
+
+// Take gives the exact number to the owner, reserved numbers included.
+func (allocator *Allocator) Take(number int64, owner string) error {
+	if allocator.rules.Exclude.Contains(number) {
+		return fmt.Errorf("number %v of %q is excluded", number, owner)
+	}
+	if other, ok := allocator.owners[number]; ok {
+		return &CollisionError{Number: number, Owner: other, Other: owner}
+	}
+	allocator.owners[number] = owner
+	return nil
+}
+
+// free tells if the number can be allocated in the block (nil for the common pool).
+func (allocator *Allocator) free(number int64, block *Block) bool {
+	if _, ok := allocator.owners[number]; ok {
+		return false
+	}
+	if allocator.rules.Reserved.Contains(number) || allocator.rules.Exclude.Contains(number) || allocator.rules.Spares.Contains(number) {
+		return false
+	}
+	if block == nil {
+		for i := range allocator.rules.Blocks {
+			if allocator.rules.Blocks[i].contains(number) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Next gives the smallest free number which is not reserved, from the category
+// block if it has one. ok is false if the block is full.
+func (allocator *Allocator) Next(owner, category string) (number int64, ok bool) {
+	block := allocator.rules.block(category)
+
+	number, started := allocator.next[block]
+	if !started {
+		number = 1
+		if block != nil {
+			number = block.Start
+		}
+	}
+
+	for !allocator.free(number, block) {
+		number++
+	}
+
+	if block != nil && !block.contains(number) {
+		allocator.next[block] = number
+		return 0, false
+	}
+
+	allocator.next[block] = number + 1
+	allocator.owners[number] = owner
+	return number, true
+}
+
+// NextSpare gives the smallest free spare number of the category block, or
+// outside of all blocks if the category has none. ok is false if there are no
+// spares left.
+func (allocator *Allocator) NextSpare(owner, category string) (number int64, ok bool) {
+	block := allocator.rules.block(category)
+
+	for _, number := range allocator.rules.Spares.Numbers(0) {
+		if _, taken := allocator.owners[number]; taken {
+			continue
+		}
+		if allocator.rules.blockOf(number) != block {
+			continue
+		}
+		allocator.owners[number] = owner
+		return number, true
+	}
+
+	return 0, false
+}
+
+// Assign gives start numbers to paid participants in the given order, so
+// inside of every block numbers go by rating then name if participants are
+// sorted this way. Personal numbers, i.e. start numbers already set, are kept
+// and allocated first. Held numbers belong to someone else (e.g. printed bibs
+// of riders who left) and are not given to anybody. If useSpares is set (bibs
+// are printed already), new participants get spare numbers first. All collisions
+// and overflown blocks are reported together. Printed numbers are set according
+// to the rules format.
+func Assign(participants []participant.Participant, rules *Rules, held map[int64]string, useSpares bool) error {
+	allocator := NewAllocator(rules)
+
+	var collisions []string
+
+	for number, owner := range held {
+		allocator.owners[number] = owner
+	}
+
+	for _, p := range participants {
+		if p.StartNumber == 0 {
+			continue
+		}
+		if err := allocator.Take(p.StartNumber, p.Name()); err != nil {
+			collisions = append(collisions, err.Error())
+		}
+	}
+
+	if len(collisions) != 0 {
+		return fmt.Errorf("invalid personal start numbers:\n%v", strings.Join(collisions, "\n"))
+	}
+
+	overflows := make(map[*Block][]string)
+
+	for i, p := range participants {
+		if p.StartNumber != 0 || !p.Paid {
+			continue
+		}
+		if useSpares {
+			if number, ok := allocator.NextSpare(p.Name(), p.Category); ok {
+				participants[i].StartNumber = number
+				continue
+			}
+		}
+		number, ok := allocator.Next(p.Name(), p.Category)
+		if !ok {
+			block := rules.block(p.Category)
+			overflows[block] = append(overflows[block], p.Name())
+			continue
+		}
+		participants[i].StartNumber = number
+	}
+
+	if len(overflows) != 0 {
+		messages := make([]string, 0, len(overflows))
+		for i := range rules.Blocks {
+			names, ok := overflows[&rules.Blocks[i]]
+			if ok {
+				messages = append(messages, fmt.Sprintf("block %v is full, %v participants don't fit: %v",
+					&rules.Blocks[i], len(names), strings.Join(names, ", ")))
+			}
+		}
+		return fmt.Errorf("%v", strings.Join(messages, "\n"))
+	}
+
+	for i, p := range participants {
+		participants[i].NumberText = ""
+		if p.StartNumber == 0 {
+			continue
+		}
+		if text := rules.Format(p.StartNumber); text != fmt.Sprintf("%v", p.StartNumber) {
+			participants[i].NumberText = text
+		}
+	}
+
+	return nil
+}
+
+// FreeSpares lists spare numbers which are neither given to participants nor held.
+func FreeSpares(participants []participant.Participant, rules *Rules, held map[int64]string) (numbers []int64) {
+	taken := make(map[int64]bool, len(participants))
+	for _, p := range participants {
+		taken[p.StartNumber] = true
+	}
+	for _, number := range rules.Spares.Numbers(0) {
+		if _, ok := held[number]; !ok && !taken[number] {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers
+}
@@ -0,0 +1,209 @@
+package numbering
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+func testRules(t *testing.T, config string) *Rules {
+	t.Helper()
+	var rules Rules
+	if err := json.Unmarshal([]byte(config), &rules); err != nil {
+		t.Fatalf("invalid rules %v: %v", config, err)
+	}
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("invalid rules %v: %v", config, err)
+	}
+	return &rules
+}
+
+// rider is a paid participant, number is a personal number if not 0.
+func rider(name, category string, number int64) participant.Participant {
+	p := participant.New()
+	_ = p.SetValue(participant.FieldName, name)
+	p.Category = category
+	p.StartNumber = number
+	p.Paid = true
+	return p
+}
+
+func TestAssign(t *testing.T) {
+	unpaid := rider("Unpaid Rider", "М30-39", 0)
+	unpaid.Paid = false
+
+	tests := []struct {
+		name      string
+		rules     string
+		riders    []participant.Participant
+		held      map[int64]string
+		useSpares bool
+		want      []int64
+		wantErr   string
+	}{
+		{
+			name:   "in order",
+			rules:  `{}`,
+			riders: []participant.Participant{rider("A a", "", 0), rider("B b", "", 0), rider("C c", "", 0)},
+			want:   []int64{1, 2, 3},
+		},
+		{
+			name:   "personal number is kept and skipped by others",
+			rules:  `{}`,
+			riders: []participant.Participant{rider("A a", "", 0), rider("B b", "", 0), rider("C c", "", 2)},
+			want:   []int64{1, 3, 2},
+		},
+		{
+			name:   "reserved numbers are personal only",
+			rules:  `{"reserved": "1,3"}`,
+			riders: []participant.Participant{rider("A a", "", 0), rider("B b", "", 0), rider("C c", "", 3)},
+			want:   []int64{2, 4, 3},
+		},
+		{
+			name:   "excluded numbers are skipped",
+			rules:  `{"exclude": "2"}`,
+			riders: []participant.Participant{rider("A a", "", 0), rider("B b", "", 0)},
+			want:   []int64{1, 3},
+		},
+		{
+			name:    "excluded personal number",
+			rules:   `{"exclude": "13"}`,
+			riders:  []participant.Participant{rider("A a", "", 13)},
+			wantErr: `number 13 of "A a" is excluded`,
+		},
+		{
+			name:    "personal number collision",
+			rules:   `{}`,
+			riders:  []participant.Participant{rider("A a", "", 5), rider("B b", "", 5)},
+			wantErr: `number 5 of "A a" is also claimed by "B b"`,
+		},
+		{
+			name:   "unpaid get no number",
+			rules:  `{}`,
+			riders: []participant.Participant{unpaid, rider("A a", "", 0)},
+			want:   []int64{0, 1},
+		},
+		{
+			name:   "blocks",
+			rules:  `{"blocks": [{"categories": ["ж*"], "start": 2, "size": 2}]}`,
+			riders: []participant.Participant{rider("A a", "М18-29", 0), rider("B b", "Ж18-39", 0), rider("C c", "М18-29", 0), rider("D d", "Ж40+", 0)},
+			want:   []int64{1, 2, 4, 3},
+		},
+		{
+			name:    "block overflow",
+			rules:   `{"blocks": [{"categories": ["Ж*"], "start": 10, "size": 1}]}`,
+			riders:  []participant.Participant{rider("A a", "Ж18-39", 0), rider("B b", "Ж40+", 0)},
+			wantErr: `block 10-10 (Ж*) is full, 1 participants don't fit: B b`,
+		},
+		{
+			name:   "spares are not given before printing",
+			rules:  `{"spares": "1-2"}`,
+			riders: []participant.Participant{rider("A a", "", 0)},
+			want:   []int64{3},
+		},
+		{
+			name:      "spares are given first after printing",
+			rules:     `{"spares": "5-6"}`,
+			riders:    []participant.Participant{rider("A a", "", 0), rider("B b", "", 0), rider("C c", "", 0)},
+			useSpares: true,
+			want:      []int64{5, 6, 1},
+		},
+		{
+			name:      "spares of the block",
+			rules:     `{"spares": "3,12", "blocks": [{"categories": ["Ж*"], "start": 10, "size": 5}]}`,
+			riders:    []participant.Participant{rider("A a", "Ж18-39", 0), rider("B b", "М18-29", 0)},
+			useSpares: true,
+			want:      []int64{12, 3},
+		},
+		{
+			name:   "held numbers are not given away",
+			rules:  `{}`,
+			riders: []participant.Participant{rider("A a", "", 0), rider("C c", "", 0)},
+			held:   map[int64]string{1: "B b"},
+			want:   []int64{2, 3},
+		},
+		{
+			name:    "personal number held by a rider who left",
+			rules:   `{}`,
+			riders:  []participant.Participant{rider("A a", "", 1)},
+			held:    map[int64]string{1: "B b"},
+			wantErr: `number 1 of "B b" is also claimed by "A a"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Assign(test.riders, testRules(t, test.rules), test.held, test.useSpares)
+			if len(test.wantErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]int64, len(test.riders))
+			for i, p := range test.riders {
+				got[i] = p.StartNumber
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("numbers %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAssignFormat(t *testing.T) {
+	rules := testRules(t, `{"width": 3, "blocks": [{"categories": ["Ж*"], "start": 200, "size": 100, "prefix": "Ж"}]}`)
+	riders := []participant.Participant{rider("A a", "М18-29", 0), rider("B b", "Ж18-39", 0)}
+
+	if err := Assign(riders, rules, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []string{"001", "Ж200"} {
+		if got := riders[i].Value(participant.FieldStartNumber); got != want {
+			t.Errorf("%v: printed number %q, want %q", riders[i].Name(), got, want)
+		}
+	}
+}
+
+func TestFreeSpares(t *testing.T) {
+	rules := testRules(t, `{"spares": "5-8"}`)
+	riders := []participant.Participant{rider("A a", "", 5)}
+
+	got := FreeSpares(riders, rules, map[int64]string{7: "B b"})
+	if want := []int64{6, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("free spares %v, want %v", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		config  string
+		wantErr string
+	}{
+		{`{"reserved": "100-"}`, "reserved: open-ended range"},
+		{`{"spares": "10-12", "exclude": "11"}`, "spare number 11 is reserved or excluded"},
+		{`{"prefix": "2021-"}`, `invalid prefix "2021-"`},
+		{`{"blocks": [{"categories": ["Ж*"], "start": 1, "size": 10, "prefix": "1"}]}`, `block 1: invalid prefix "1"`},
+		{`{"blocks": [{"categories": ["["], "start": 1, "size": 10}]}`, `block 1: invalid category pattern "["`},
+		{`{"blocks": [{"categories": ["Ж*"], "start": 1, "size": 10}, {"categories": ["М*"], "start": 10, "size": 10}]}`, "overlaps"},
+	}
+
+	for _, test := range tests {
+		var rules Rules
+		if err := json.Unmarshal([]byte(test.config), &rules); err != nil {
+			t.Fatalf("%v: %v", test.config, err)
+		}
+		err := rules.Validate()
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%v: error %v, want %q", test.config, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package numbering
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+// ChangeKind tells how a rider's bib differs between two assignments.
+type ChangeKind string
+
+const (
+	Added      ChangeKind = "added"
+	Removed    ChangeKind = "removed"
+	Renumbered ChangeKind = "renumbered"
+	Renamed    ChangeKind = "renamed" // same number, another rider
+	Changed    ChangeKind = "changed" // same rider and number, other printed fields
+)
+
+// Change is a difference of a single bib between assignments.
+type Change struct {
+	Kind      ChangeKind
+	Number    int64 // new number, old one for removed riders
+	OldNumber int64
+	Name      string
+	OldName   string
+	Details   string // what else changed: team, category
+}
+
+func (change Change) String() string {
+	switch change.Kind {
+	case Added:
+		return fmt.Sprintf("%v: %v %q", change.Kind, change.Number, change.Name)
+	case Removed:
+		return fmt.Sprintf("%v: %v %q", change.Kind, change.Number, change.OldName)
+	case Renumbered:
+		return fmt.Sprintf("%v: %q %v -> %v", change.Kind, change.Name, change.OldNumber, change.Number)
+	case Renamed:
+		return fmt.Sprintf("%v: %v %q -> %q", change.Kind, change.Number, change.OldName, change.Name)
+	}
+	return fmt.Sprintf("%v: %v %q %v", change.Kind, change.Number, change.Name, change.Details)
+}
+
+// Diff compares two assignments. Riders are matched by normalized name, a number
+// which changed its rider is renamed. Riders without numbers and held numbers
+// of riders who left are ignored.
+func Diff(previous, current []participant.Participant) (changes []Change) {
+	previous = numbered(previous)
+	current = numbered(current)
+
+	// Queues by name, so namesakes are matched in order
+	currentByName := make(map[string][]int)
+	for i, p := range current {
+		name := participant.NormalizeName(p.Name())
+		currentByName[name] = append(currentByName[name], i)
+	}
+
+	matched := make(map[int]bool)
+	var unmatched []participant.Participant
+
+	for _, old := range previous {
+		name := participant.NormalizeName(old.Name())
+		queue := currentByName[name]
+		if len(queue) == 0 {
+			unmatched = append(unmatched, old)
+			continue
+		}
+
+		i := queue[0]
+		currentByName[name] = queue[1:]
+		matched[i] = true
+
+		p := current[i]
+		switch {
+		case p.StartNumber != old.StartNumber:
+			changes = append(changes, Change{Kind: Renumbered, Number: p.StartNumber, OldNumber: old.StartNumber, Name: p.Name(), OldName: old.Name()})
+		case printedDetails(old, p) != "":
+			changes = append(changes, Change{Kind: Changed, Number: p.StartNumber, OldNumber: old.StartNumber, Name: p.Name(), OldName: old.Name(), Details: printedDetails(old, p)})
+		}
+	}
+
+	currentByNumber := make(map[int64]int)
+	for i, p := range current {
+		if !matched[i] {
+			currentByNumber[p.StartNumber] = i
+		}
+	}
+
+	for _, old := range unmatched {
+		if i, ok := currentByNumber[old.StartNumber]; ok {
+			delete(currentByNumber, old.StartNumber)
+			matched[i] = true
+			changes = append(changes, Change{Kind: Renamed, Number: old.StartNumber, OldNumber: old.StartNumber, Name: current[i].Name(), OldName: old.Name()})
+			continue
+		}
+		changes = append(changes, Change{Kind: Removed, Number: old.StartNumber, OldNumber: old.StartNumber, OldName: old.Name()})
+	}
+
+	for i, p := range current {
+		if !matched[i] {
+			changes = append(changes, Change{Kind: Added, Number: p.StartNumber, Name: p.Name()})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Number < changes[j].Number
+	})
+
+	return changes
+}
+
+func numbered(participants []participant.Participant) (result []participant.Participant) {
+	for _, p := range participants {
+		if p.StartNumber != 0 && len(strings.TrimSpace(p.Name())) != 0 && !p.Held {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// printedDetails lists other bib fields which differ, empty if none. The name is
+// compared as printed, riders are matched ignoring case, ё and hyphens.
+func printedDetails(old, p participant.Participant) string {
+	var details []string
+	for _, field := range []participant.Field{participant.FieldName, participant.FieldTeam, participant.FieldCategory, participant.FieldStartNumber} {
+		if old.Value(field) != p.Value(field) {
+			details = append(details, fmt.Sprintf("%v %q -> %q", field, old.Value(field), p.Value(field)))
+		}
+	}
+	return strings.Join(details, ", ")
+}
+
+// Change report columns
+var changeHeader = []string{"change", "number", "old number", "name", "old name", "details"}
+
+// WriteChanges writes changes as csv.
+func WriteChanges(w io.Writer, changes []Change) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(changeHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		oldNumber := ""
+		if change.OldNumber != 0 {
+			oldNumber = fmt.Sprintf("%v", change.OldNumber)
+		}
+		err = writer.Write([]string{
+			string(change.Kind),
+			fmt.Sprintf("%v", change.Number),
+			oldNumber,
+			change.Name,
+			change.OldName,
+			change.Details,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadChanges reads changes written by WriteChanges.
+func ReadChanges(r io.Reader) (changes []Change, err error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	if strings.Join(records[0], ",") != strings.Join(changeHeader, ",") {
+		return nil, fmt.Errorf("unexpected header %q, expected %q", records[0], changeHeader)
+	}
+
+	for i, record := range records[1:] {
+		row := i + 2
+
+		change := Change{
+			Kind:    ChangeKind(record[0]),
+			Name:    record[3],
+			OldName: record[4],
+			Details: record[5],
+		}
+
+		switch change.Kind {
+		case Added, Removed, Renumbered, Renamed, Changed:
+		default:
+			return nil, fmt.Errorf("row %v: unknown change %q", row, record[0])
+		}
+
+		change.Number, err = strconv.ParseInt(strings.TrimSpace(record[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %v: invalid number %q", row, record[1])
+		}
+		if oldNumber := strings.TrimSpace(record[2]); len(oldNumber) != 0 {
+			change.OldNumber, err = strconv.ParseInt(oldNumber, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %v: invalid old number %q", row, record[2])
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// Numbers lists bibs which must be re-rendered: new numbers of riders and
+// numbers which lost their riders.
+func Numbers(changes []Change) Ranges {
+	var numbers []int64
+	for _, change := range changes {
+		numbers = append(numbers, change.Number)
+		if change.Kind == Renumbered {
+			numbers = append(numbers, change.OldNumber)
+		}
+	}
+	return RangesOf(numbers)
+}
@@ -0,0 +1,122 @@
+package numbering
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+func TestDiff(t *testing.T) {
+	held := rider("B b", "", 2)
+	held.Held = true
+
+	moved := rider("A a", "", 1)
+	moved.Team = "Ночная Лига"
+
+	tests := []struct {
+		name     string
+		previous []participant.Participant
+		current  []participant.Participant
+		want     []Change
+		numbers  string
+	}{
+		{
+			name:     "same",
+			previous: []participant.Participant{rider("A a", "", 1), rider("B b", "", 2)},
+			current:  []participant.Participant{rider("B b", "", 2), rider("A a", "", 1)},
+		},
+		{
+			name:     "added and removed",
+			previous: []participant.Participant{rider("A a", "", 1), rider("B b", "", 2)},
+			current:  []participant.Participant{rider("A a", "", 1), rider("C c", "", 3)},
+			want: []Change{
+				{Kind: Removed, Number: 2, OldNumber: 2, OldName: "B b"},
+				{Kind: Added, Number: 3, Name: "C c"},
+			},
+			numbers: "2-3",
+		},
+		{
+			name:     "renumbered",
+			previous: []participant.Participant{rider("A a", "", 1)},
+			current:  []participant.Participant{rider("A a", "", 5)},
+			want:     []Change{{Kind: Renumbered, Number: 5, OldNumber: 1, Name: "A a", OldName: "A a"}},
+			numbers:  "1,5",
+		},
+		{
+			name:     "renamed",
+			previous: []participant.Participant{rider("A a", "", 1)},
+			current:  []participant.Participant{rider("C c", "", 1)},
+			want:     []Change{{Kind: Renamed, Number: 1, OldNumber: 1, Name: "C c", OldName: "A a"}},
+			numbers:  "1",
+		},
+		{
+			name:     "team changed",
+			previous: []participant.Participant{rider("A a", "", 1)},
+			current:  []participant.Participant{moved},
+			want:     []Change{{Kind: Changed, Number: 1, OldNumber: 1, Name: "A a", OldName: "A a", Details: `team "" -> "Ночная Лига"`}},
+			numbers:  "1",
+		},
+		{
+			name:     "printed name spelling changed",
+			previous: []participant.Participant{rider("Лихачев Денис", "", 7)},
+			current:  []participant.Participant{rider("Лихачёв Денис", "", 7)},
+			want: []Change{{Kind: Changed, Number: 7, OldNumber: 7, Name: "Лихачёв Денис", OldName: "Лихачев Денис",
+				Details: `name "Лихачев Денис" -> "Лихачёв Денис"`}},
+			numbers: "7",
+		},
+		{
+			name:     "namesakes are matched in order",
+			previous: []participant.Participant{rider("Иванов Иван", "", 1), rider("Иванов Иван", "", 2)},
+			current:  []participant.Participant{rider("Иванов Иван", "", 1), rider("Иванов Иван", "", 3)},
+			want:     []Change{{Kind: Renumbered, Number: 3, OldNumber: 2, Name: "Иванов Иван", OldName: "Иванов Иван"}},
+			numbers:  "2-3",
+		},
+		{
+			name:     "held numbers are not riders",
+			previous: []participant.Participant{rider("A a", "", 1), held},
+			current:  []participant.Participant{rider("A a", "", 1), held},
+		},
+		{
+			name:     "rider leaves, the number is held",
+			previous: []participant.Participant{rider("A a", "", 1), rider("B b", "", 2)},
+			current:  []participant.Participant{rider("A a", "", 1), held},
+			want:     []Change{{Kind: Removed, Number: 2, OldNumber: 2, OldName: "B b"}},
+			numbers:  "2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Diff(test.previous, test.current)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("changes %v, want %v", got, test.want)
+			}
+			if numbers := Numbers(got).String(); numbers != test.numbers {
+				t.Errorf("numbers %q, want %q", numbers, test.numbers)
+			}
+		})
+	}
+}
+
+func TestChangesRoundTrip(t *testing.T) {
+	changes := []Change{
+		{Kind: Added, Number: 3, Name: "C c"},
+		{Kind: Renumbered, Number: 5, OldNumber: 1, Name: "A a", OldName: "A a"},
+		{Kind: Changed, Number: 7, OldNumber: 7, Name: "Лихачёв Денис", OldName: "Лихачев Денис", Details: `name "Лихачев Денис" -> "Лихачёв Денис"`},
+	}
+
+	var buffer bytes.Buffer
+	if err := WriteChanges(&buffer, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadChanges(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, changes) {
+		t.Errorf("read %v, want %v", got, changes)
+	}
+}
@@ -0,0 +1,183 @@
+// Package numbering contains start number ranges and allocation rules.
+package numbering
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Range is an inclusive range of start numbers. Zero To means open-ended range.
+type Range struct {
+	From int64
+	To   int64
+}
+
+// Ranges is a list of ranges like "1-20,45,100-".
+type Ranges []Range
+
+// ParseRanges parses comma separated numbers and ranges: "1-20,45,100-".
+func ParseRanges(s string) (ranges Ranges, err error) {
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		var r Range
+
+		if i := strings.Index(item, "-"); i >= 0 {
+			r.From, err = parseNumber(item[:i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q: %v", item, err)
+			}
+			if to := strings.TrimSpace(item[i+1:]); len(to) != 0 {
+				r.To, err = parseNumber(to)
+				if err != nil {
+					return nil, fmt.Errorf("invalid range %q: %v", item, err)
+				}
+				if r.To < r.From {
+					return nil, fmt.Errorf("invalid range %q: end is less than start", item)
+				}
+			}
+		} else {
+			r.From, err = parseNumber(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q: %v", item, err)
+			}
+			r.To = r.From
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+func parseNumber(s string) (int64, error) {
+	number, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a number")
+	}
+	if number <= 0 {
+		return 0, fmt.Errorf("numbers start from 1")
+	}
+	return number, nil
+}
+
+// OpenEnded tells if the range has no end.
+func (r Range) OpenEnded() bool {
+	return r.To == 0
+}
+
+// Contains tells if the number is in the range.
+func (r Range) Contains(number int64) bool {
+	return number >= r.From && (r.OpenEnded() || number <= r.To)
+}
+
+func (r Range) String() string {
+	switch {
+	case r.OpenEnded():
+		return fmt.Sprintf("%v-", r.From)
+	case r.From == r.To:
+		return fmt.Sprintf("%v", r.From)
+	default:
+		return fmt.Sprintf("%v-%v", r.From, r.To)
+	}
+}
+
+// Contains tells if the number is in any of the ranges.
+func (ranges Ranges) Contains(number int64) bool {
+	for _, r := range ranges {
+		if r.Contains(number) {
+			return true
+		}
+	}
+	return false
+}
+
+// Numbers lists all numbers of the ranges in ascending order without duplicates.
+// Open-ended ranges stop at max.
+func (ranges Ranges) Numbers(max int64) (numbers []int64) {
+	seen := make(map[int64]bool)
+
+	for _, r := range ranges {
+		to := r.To
+		if r.OpenEnded() {
+			to = max
+		}
+		for number := r.From; number <= to; number++ {
+			if !seen[number] {
+				seen[number] = true
+				numbers = append(numbers, number)
+			}
+		}
+	}
+
+	sort.Slice(numbers, func(i, j int) bool {
+		return numbers[i] < numbers[j]
+	})
+
+	return numbers
+}
+
+func (ranges Ranges) String() string {
+	items := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		items = append(items, r.String())
+	}
+	return strings.Join(items, ",")
+}
+
+// UnmarshalJSON reads ranges from a string like "1-20,45,100-".
+func (ranges *Ranges) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("ranges should be a string like \"1-20,45\"")
+	}
+
+	parsed, err := ParseRanges(s)
+	if err != nil {
+		return err
+	}
+
+	*ranges = parsed
+	return nil
+}
+
+// MarshalJSON writes ranges as a string.
+func (ranges Ranges) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ranges.String())
+}
+
+// Bounded tells if none of the ranges is open-ended.
+func (ranges Ranges) Bounded() bool {
+	for _, r := range ranges {
+		if r.OpenEnded() {
+			return false
+		}
+	}
+	return true
+}
+
+// RangesOf packs numbers to ranges: 1,2,3,5 -> "1-3,5".
+func RangesOf(numbers []int64) (ranges Ranges) {
+	sorted := append([]int64(nil), numbers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	for _, number := range sorted {
+		if last := len(ranges) - 1; last >= 0 && number <= ranges[last].To+1 {
+			if number > ranges[last].To {
+				ranges[last].To = number
+			}
+			continue
+		}
+		ranges = append(ranges, Range{From: number, To: number})
+	}
+
+	return ranges
+}
@@ -0,0 +1,158 @@
+package participant
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Column maps a participant field to a csv column header. Aliases are tried in
+// order when the sheet doesn't have the main header, this lets one config survive
+// small changes of the registration form.
+type Column struct {
+	Field    Field    `json:"field"`
+	Header   string   `json:"header"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Required bool     `json:"required,omitempty"`
+}
+
+// Columns is an ordered list of columns, the order is used when writing csv files.
+type Columns []Column
+
+var (
+	// RegistrationColumns are the columns of the registration google sheet.
+	RegistrationColumns = Columns{
+		{Field: FieldLastName, Header: "Фамилия", Required: true},
+		{Field: FieldFirstName, Header: "Имя", Required: true},
+		{Field: FieldMiddleName, Header: "Отчество"},
+		{Field: FieldCategory, Header: "Категория"},
+		{Field: FieldPaid, Header: "Оплата_"},
+		{Field: FieldPhone, Header: "Телефон"},
+		{Field: FieldTeam, Header: "Клуб/команда", Aliases: []string{"Команда", "Клуб"}},
+		{Field: FieldBirthDate, Header: "Дата рождения"},
+		{Field: FieldGender, Header: "Пол"},
+		{Field: FieldTimestamp, Header: "Timestamp", Aliases: []string{"Отметка времени"}},
+		{Field: FieldStartNumber, Header: "Именной номер"},
+	}
+
+	// RatingColumns are the columns of the rating file.
+	RatingColumns = Columns{
+		{Field: FieldRating, Header: "number", Required: true},
+		{Field: FieldLastName, Header: "lastname", Required: true},
+		{Field: FieldFirstName, Header: "firstname", Required: true},
+	}
+
+	// RatedColumns are the columns of the file with assigned start numbers.
+	RatedColumns = Columns{
+		{Field: FieldStartNumber, Header: "number", Required: true},
+		{Field: FieldName, Header: "name", Required: true},
+		{Field: FieldTeam, Header: "team"},
+		{Field: FieldCategory, Header: "category"},
+		{Field: FieldTimestamp, Header: "timestamp"},
+		{Field: FieldHeld, Header: "held"},
+	}
+
+	// ResultsColumns are the columns of the finish results file.
+	ResultsColumns = Columns{
+		{Field: FieldStartNumber, Header: "number", Required: true},
+		{Field: FieldCategory, Header: "category"},
+		{Field: FieldFinishTime, Header: "time", Required: true},
+	}
+)
+
+// Require returns a copy of columns where the given fields are required.
+func (columns Columns) Require(fields ...Field) Columns {
+	result := make(Columns, len(columns))
+	copy(result, columns)
+
+	for i, column := range result {
+		for _, field := range fields {
+			if column.Field == field {
+				result[i].Required = true
+			}
+		}
+	}
+
+	return result
+}
+
+// Validate checks that all fields are known and that no field is mapped twice.
+func (columns Columns) Validate() error {
+	seen := make(map[Field]bool, len(columns))
+
+	for _, column := range columns {
+		if !column.Field.valid() {
+			return fmt.Errorf("unknown field %q", column.Field)
+		}
+		if len(column.Header) == 0 {
+			return fmt.Errorf("field %q has no header", column.Field)
+		}
+		if seen[column.Field] {
+			return fmt.Errorf("field %q is mapped more than once", column.Field)
+		}
+		seen[column.Field] = true
+	}
+
+	return nil
+}
+
+func (column Column) headers() []string {
+	return append([]string{column.Header}, column.Aliases...)
+}
+
+func normalizeHeader(header string) string {
+	return strings.ToLower(strings.TrimSpace(header))
+}
+
+// Indexes finds each column in the csv header. Optional columns which are absent
+// are left out of the result, absent required ones are reported all at once.
+func (columns Columns) Indexes(header []string) (result map[Field]int, err error) {
+	headerIndexes := make(map[string]int, len(header))
+	for i, h := range header {
+		h = normalizeHeader(h)
+		if _, ok := headerIndexes[h]; !ok {
+			headerIndexes[h] = i
+		}
+	}
+
+	result = make(map[Field]int, len(columns))
+	var missing []Column
+
+	for _, column := range columns {
+		found := false
+		for _, h := range column.headers() {
+			if index, ok := headerIndexes[normalizeHeader(h)]; ok {
+				result[column.Field] = index
+				found = true
+				break
+			}
+		}
+		if !found && column.Required {
+			missing = append(missing, column)
+		}
+	}
+
+	if len(missing) != 0 {
+		return nil, &MissingColumnsError{Missing: missing, Header: header}
+	}
+
+	return result, nil
+}
+
+// MissingColumnsError lists all required columns which are not found in a csv file.
+type MissingColumnsError struct {
+	Missing []Column
+	Header  []string
+}
+
+func (e *MissingColumnsError) Error() string {
+	descriptions := make([]string, 0, len(e.Missing))
+	for _, column := range e.Missing {
+		quoted := make([]string, 0, len(column.headers()))
+		for _, h := range column.headers() {
+			quoted = append(quoted, fmt.Sprintf("%q", h))
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%v (%v)", column.Field, strings.Join(quoted, " or ")))
+	}
+	return fmt.Sprintf("missing required columns: %v; file has columns: %q",
+		strings.Join(descriptions, ", "), e.Header)
+}
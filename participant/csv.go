@@ -0,0 +1,168 @@
+package participant
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Error describes a problem with a specific row and column of a csv file.
+type Error struct {
+	Row    int // 1-based like in spreadsheets, header is row 1
+	Column string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("row %v, column %q: %v", e.Row, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors is a list of all problems found in a csv file.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ReadCsvFile reads all records of the csv file, including the header.
+func ReadCsvFile(csvFilePath string) (records [][]string, err error) {
+	file, err := os.Open(csvFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	csvReader := csv.NewReader(file)
+	//csvReader.Comma = ';'
+
+	records, err = csvReader.ReadAll()
+
+	return
+}
+
+// CsvRecordsToMap converts records to maps from header to value, header row is skipped.
+func CsvRecordsToMap(records [][]string) (result []map[string]string) {
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	result = make([]map[string]string, 0, len(records))
+
+	var header []string
+
+	for line, record := range records {
+		if line == 0 {
+			header = record
+			continue
+		}
+
+		recordMap := make(map[string]string)
+
+		for col, value := range record {
+			if col >= len(header) {
+				break
+			}
+			colName := header[col]
+			recordMap[colName] = value
+		}
+
+		result = append(result, recordMap)
+	}
+
+	return
+}
+
+// ReadFile reads participants from the csv file. See Read.
+func ReadFile(csvFilePath string, columns Columns) (participants []Participant, err error) {
+	records, err := ReadCsvFile(csvFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromRecords(records, columns)
+}
+
+// Read reads participants from csv. See FromRecords.
+func Read(r io.Reader, columns Columns) (participants []Participant, err error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return FromRecords(records, columns)
+}
+
+// FromRecords converts csv records (with header) to participants. Required columns
+// must be present in the header, otherwise MissingColumnsError is returned.
+// Unparsable values don't stop loading: every row is returned and all problems are
+// reported together as Errors.
+func FromRecords(records [][]string, columns Columns) (participants []Participant, err error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	indexes, err := columns.Indexes(records[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var errs Errors
+
+	participants = make([]Participant, 0, len(records)-1)
+
+	for i, record := range records[1:] {
+		p := New()
+
+		for _, column := range columns {
+			index, ok := indexes[column.Field]
+			if !ok || index >= len(record) {
+				continue
+			}
+			if err := p.SetValue(column.Field, record[index]); err != nil {
+				errs = append(errs, &Error{Row: i + 2, Column: column.Header, Err: err})
+			}
+		}
+
+		participants = append(participants, p)
+	}
+
+	if len(errs) != 0 {
+		return participants, errs
+	}
+
+	return participants, nil
+}
+
+// Write writes participants as csv with a header made of columns.
+func Write(w io.Writer, participants []Participant, columns Columns) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.Header)
+	}
+	writer.Write(header)
+
+	for _, p := range participants {
+		lineArray := make([]string, 0, len(columns))
+		for _, column := range columns {
+			lineArray = append(lineArray, p.Value(column.Field))
+		}
+		writer.Write(lineArray)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
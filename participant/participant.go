@@ -0,0 +1,246 @@
+// Package participant contains the participant model shared by all race-numbers
+// commands together with csv loading and writing helpers.
+package participant
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// FakeLowRating is the rating of participants who are not present in the rating file.
+	FakeLowRating = 1_000_000
+)
+
+// Participant is a single rider as read from registration, rating, rated or results csv files.
+type Participant struct {
+	FirstName   string
+	LastName    string
+	MiddleName  string
+	Team        string
+	Category    string
+	Phone       string
+	Paid        bool
+	Rating      int64
+	StartNumber int64
+	NumberText  string // start number as printed, e.g. "M012", empty means plain StartNumber
+	FinishTime  string
+	BirthDate   string
+	Gender      string
+	Timestamp   string // registration form submission time, identifies the row
+	Held        bool   // rider left, the printed bib keeps the number from being given away
+}
+
+// Field is a logical participant field which can be mapped to a csv column.
+type Field string
+
+const (
+	FieldFirstName   Field = "firstName"
+	FieldLastName    Field = "lastName"
+	FieldName        Field = "name" // "Lastname Firstname" in a single column
+	FieldMiddleName  Field = "middleName"
+	FieldTeam        Field = "team"
+	FieldCategory    Field = "category"
+	FieldPhone       Field = "phone"
+	FieldPaid        Field = "paid"
+	FieldRating      Field = "rating"
+	FieldStartNumber Field = "startNumber"
+	FieldFinishTime  Field = "finishTime"
+	FieldBirthDate   Field = "birthDate"
+	FieldGender      Field = "gender"
+	FieldTimestamp   Field = "timestamp"
+	FieldHeld        Field = "held"
+)
+
+var fields = []Field{
+	FieldFirstName,
+	FieldLastName,
+	FieldName,
+	FieldMiddleName,
+	FieldTeam,
+	FieldCategory,
+	FieldPhone,
+	FieldPaid,
+	FieldRating,
+	FieldStartNumber,
+	FieldFinishTime,
+	FieldBirthDate,
+	FieldGender,
+	FieldTimestamp,
+	FieldHeld,
+}
+
+func (field Field) valid() bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns an empty participant which is not yet rated.
+func New() Participant {
+	return Participant{Rating: FakeLowRating}
+}
+
+// Name returns "Lastname Firstname", this is the key used to join files with each other.
+func (p Participant) Name() string {
+	return strings.TrimSpace(fmt.Sprintf("%v %v", p.LastName, p.FirstName))
+}
+
+// Value returns csv representation of the field.
+func (p Participant) Value(field Field) string {
+	switch field {
+	case FieldFirstName:
+		return p.FirstName
+	case FieldLastName:
+		return p.LastName
+	case FieldName:
+		return p.Name()
+	case FieldMiddleName:
+		return p.MiddleName
+	case FieldTeam:
+		return p.Team
+	case FieldCategory:
+		return p.Category
+	case FieldPhone:
+		return p.Phone
+	case FieldPaid:
+		if p.Paid {
+			return "+"
+		}
+		return ""
+	case FieldRating:
+		if p.Rating == FakeLowRating {
+			return ""
+		}
+		return fmt.Sprintf("%v", p.Rating)
+	case FieldStartNumber:
+		if p.StartNumber == 0 {
+			return ""
+		}
+		if len(p.NumberText) != 0 {
+			return p.NumberText
+		}
+		return fmt.Sprintf("%v", p.StartNumber)
+	case FieldFinishTime:
+		return p.FinishTime
+	case FieldBirthDate:
+		return p.BirthDate
+	case FieldGender:
+		return p.Gender
+	case FieldTimestamp:
+		return p.Timestamp
+	case FieldHeld:
+		if p.Held {
+			return "+"
+		}
+		return ""
+	}
+	return ""
+}
+
+// SetValue parses csv representation of the field. Empty values reset the field.
+func (p *Participant) SetValue(field Field, value string) error {
+	value = strings.TrimSpace(value)
+
+	switch field {
+	case FieldFirstName:
+		p.FirstName = value
+	case FieldLastName:
+		p.LastName = value
+	case FieldName:
+		fields := strings.Fields(value)
+		p.LastName = ""
+		p.FirstName = ""
+		if len(fields) > 0 {
+			p.LastName = fields[0]
+			p.FirstName = strings.Join(fields[1:], " ")
+		}
+	case FieldMiddleName:
+		p.MiddleName = value
+	case FieldTeam:
+		p.Team = value
+	case FieldCategory:
+		// Registration form has long categories like "М40-49 – мужчины 40-49 лет"
+		p.Category = ""
+		categoryFields := strings.Fields(value)
+		if len(categoryFields) > 0 {
+			p.Category = categoryFields[0]
+		}
+	case FieldPhone:
+		p.Phone = value
+	case FieldPaid:
+		p.Paid = len(value) != 0
+	case FieldRating:
+		if len(value) == 0 {
+			p.Rating = FakeLowRating
+			return nil
+		}
+		rating, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			p.Rating = FakeLowRating
+			return fmt.Errorf("invalid rating %q", value)
+		}
+		p.Rating = rating
+	case FieldStartNumber:
+		p.StartNumber = 0
+		p.NumberText = ""
+		if len(value) == 0 {
+			return nil
+		}
+		number, err := ParseNumber(value)
+		if err != nil {
+			return err
+		}
+		p.StartNumber = number
+		if number != 0 && value != fmt.Sprintf("%v", number) {
+			p.NumberText = value
+		}
+	case FieldFinishTime:
+		p.FinishTime = value
+	case FieldBirthDate:
+		p.BirthDate = value
+	case FieldGender:
+		p.Gender = value
+	case FieldTimestamp:
+		p.Timestamp = value
+	case FieldHeld:
+		p.Held = len(value) != 0
+	default:
+		return fmt.Errorf("unknown field %q", field)
+	}
+
+	return nil
+}
+
+var numberRegexp = regexp.MustCompile(`^\D*(\d+)$`)
+
+// NormalizeName makes names comparable: " Лихачёв,  ИВАН " -> "лихачев иван".
+// Hyphens and punctuation are treated as spaces.
+func NormalizeName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("ё", "е").Replace(name)
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return strings.Join(words, " ")
+}
+
+// ParseNumber parses start number with optional prefix and zero padding: "M012" is 12.
+func ParseNumber(value string) (number int64, err error) {
+	match := numberRegexp.FindStringSubmatch(strings.TrimSpace(value))
+	if match != nil {
+		number, err = strconv.ParseInt(match[1], 10, 64)
+	}
+	if match == nil || err != nil {
+		return 0, fmt.Errorf("invalid start number %q", value)
+	}
+	return number, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ivanzoid/race-numbers/numbering"
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+func registered(names ...string) (users []participant.Participant) {
+	for _, name := range names {
+		user := participant.New()
+		_ = user.SetValue(participant.FieldName, name)
+		user.Paid = true
+		users = append(users, user)
+	}
+	return users
+}
+
+// rerun numbers registered riders locked against the previous rated file and
+// returns the new rated file as rate-participants writes it.
+func rerun(t *testing.T, previous []participant.Participant, users []participant.Participant) []participant.Participant {
+	t.Helper()
+
+	var held map[int64]string
+	if previous != nil {
+		held = lockNumbers(users, previous)
+	}
+	err := numbering.Assign(users, &numbering.Rules{}, held, previous != nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users = append(users, heldUsers(previous, held)...)
+
+	var buffer bytes.Buffer
+	if err := participant.Write(&buffer, users, participant.RatedColumns); err != nil {
+		t.Fatal(err)
+	}
+	rated, err := participant.Read(&buffer, participant.RatedColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rated
+}
+
+func numbers(users []participant.Participant) map[string]int64 {
+	result := make(map[string]int64, len(users))
+	for _, user := range users {
+		name := user.Name()
+		if user.Held {
+			name += " (held)"
+		}
+		result[name] = user.StartNumber
+	}
+	return result
+}
+
+func TestLockKeepsHeldNumbers(t *testing.T) {
+	rated := rerun(t, nil, registered("A a", "B b", "C c"))
+
+	// B leaves: the printed bib stays held
+	rated = rerun(t, rated, registered("A a", "C c"))
+	want := map[string]int64{"A a": 1, "B b (held)": 2, "C c": 3}
+	if got := numbers(rated); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after B left %v, want %v", got, want)
+	}
+
+	// Next run against that file doesn't give B's bib to a new rider
+	rated = rerun(t, rated, registered("A a", "C c", "D d"))
+	want = map[string]int64{"A a": 1, "B b (held)": 2, "C c": 3, "D d": 4}
+	if got := numbers(rated); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after D registered %v, want %v", got, want)
+	}
+
+	// B returns and gets the bib back
+	rated = rerun(t, rated, registered("A a", "C c", "D d", "B b"))
+	want = map[string]int64{"A a": 1, "B b": 2, "C c": 3, "D d": 4}
+	if got := numbers(rated); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after B returned %v, want %v", got, want)
+	}
+}
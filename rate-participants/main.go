@@ -1,18 +1,16 @@
 package main
 
 import (
-	"encoding/csv"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
-)
 
-const (
-	fakeLowRating = 1_000_000
+	"github.com/ivanzoid/race-numbers/event"
+	"github.com/ivanzoid/race-numbers/numbering"
+	"github.com/ivanzoid/race-numbers/participant"
 )
 
 // ---------------------------------------------------------------------------
@@ -26,164 +24,154 @@ func dlog(format string, args ...interface{}) {
 
 // ---------------------------------------------------------------------------
 
-type User struct {
-	firstName string
-	lastName  string
-	name      string
-	team      string
-	// points       int64
-	rating      int64
-	paid        bool
-	startNumber int64
-	surName     string
-	phone       string
-	category    string
-}
+func ratedUsersFromCsvFile(csvFilePath string) (users []participant.Participant, err error) {
 
-func readCsvFile(csvFilePath string) (records [][]string, err error) {
-	file, err := os.Open(csvFilePath)
+	users, err = participant.ReadFile(csvFilePath, participant.RatingColumns)
 	if err != nil {
 		return nil, err
 	}
 
-	defer file.Close()
-
-	csvReader := csv.NewReader(file)
-	//csvReader.Comma = ';'
-
-	records, err = csvReader.ReadAll()
+	for _, user := range users {
+		dlog("Rated user: %v, %v", user.Name(), user.Rating)
+	}
 
 	return
 }
 
-func csvRecordsToMap(records [][]string) (result []map[string]string) {
+func participantsUsersFromCsvFile(csvFilePath string, columns participant.Columns) (users []participant.Participant, err error) {
 
-	if len(records) == 0 {
-		return nil
+	users, err = participant.ReadFile(csvFilePath, columns)
+	if err != nil {
+		return nil, err
 	}
 
-	result = make([]map[string]string, 0, len(records))
-
-	var header []string
-
-	for line, record := range records {
-		if line == 0 {
-			header = record
-			continue
-		}
-
-		recordMap := make(map[string]string)
-
-		for col, value := range record {
-			if col >= len(header) {
-				break
-			}
-			colName := header[col]
-			recordMap[colName] = value
-		}
+	for i, user := range users {
+		user.Paid = true // everyone registered gets a number, payment is checked at the start
+		user.Rating = participant.FakeLowRating
+		users[i] = user
 
-		result = append(result, recordMap)
+		dlog("Participant: %v, %v", user.Name(), user.Team)
 	}
 
 	return
 }
 
-func ratedUsersFromCsvFile(csvFilePath string) (users []User, err error) {
-
-	records, err := readCsvFile(csvFilePath)
+func writeMatchesFile(fileName string, matches []ratingMatch, participants, ratedUsers []participant.Participant) (err error) {
+	file, err := os.Create(fileName)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	mapRecords := csvRecordsToMap(records)
-
-	users = make([]User, 0, len(records))
+	err = writeMatchesReport(file, matches, participants, ratedUsers, acceptScore)
+	if err != nil {
+		file.Close()
+		return err
+	}
 
-	for _, record := range mapRecords {
-		var user User
+	return file.Close()
+}
 
-		firstName := strings.TrimSpace(record["firstname"])
-		lastName := strings.TrimSpace(record["lastname"])
+// lockNumbers gives riders of the previous assignment their previous numbers.
+// Numbers of riders who are gone are returned as held, so their printed bibs
+// are not given to somebody else. Held rows of the previous file are read back
+// the same way, a rider who returns gets the number again.
+func lockNumbers(users []participant.Participant, previous []participant.Participant) (held map[int64]string) {
+	previousByName := make(map[string]participant.Participant, len(previous))
+	for _, user := range previous {
+		// Unused spare bibs have no names, they are free again
+		if user.StartNumber == 0 || len(user.Name()) == 0 {
+			continue
+		}
+		name := participant.NormalizeName(user.Name())
+		if _, ok := previousByName[name]; ok {
+			dlog("Warning: %q is twice in %v, the first number is kept", user.Name(), lockFileName)
+			continue
+		}
+		previousByName[name] = user
+	}
 
-		user.name = fmt.Sprintf("%v %v", lastName, firstName)
-		//user.points, _ = strconv.ParseInt(record["pts"], 10, 64)
-		rating, err := strconv.ParseInt(record["number"], 10, 64)
-		if err == nil {
-			user.rating = rating
-		} else {
-			user.rating = fakeLowRating
+	for i, user := range users {
+		name := participant.NormalizeName(user.Name())
+		previousUser, ok := previousByName[name]
+		if !ok {
+			continue
+		}
+		if user.StartNumber != 0 && user.StartNumber != previousUser.StartNumber {
+			dlog("Warning: %q keeps locked number %v instead of personal %v", user.Name(), previousUser.StartNumber, user.StartNumber)
 		}
-		users = append(users, user)
+		users[i].StartNumber = previousUser.StartNumber
+		delete(previousByName, name)
+	}
 
-		dlog("Rated user: %v, %v", user.name, user.rating)
+	held = make(map[int64]string, len(previousByName))
+	for _, user := range previousByName {
+		held[user.StartNumber] = user.Name()
 	}
 
-	return
+	return held
 }
 
-func participantsUsersFromCsvFile(csvFilePath string) (users []User, err error) {
+// heldUsers returns rows of the previous assignment whose numbers are held,
+// marked as held.
+func heldUsers(previous []participant.Participant, held map[int64]string) (users []participant.Participant) {
+	for _, user := range previous {
+		if owner, ok := held[user.StartNumber]; ok && owner == user.Name() {
+			user.Held = true
+			users = append(users, user)
+		}
+	}
+	return users
+}
 
-	records, err := readCsvFile(csvFilePath)
+func writeChangesFile(fileName string, changes []numbering.Change) (err error) {
+	file, err := os.Create(fileName)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	mapRecords := csvRecordsToMap(records)
-
-	users = make([]User, 0, len(records))
-
-	for _, record := range mapRecords {
-		var user User
-
-		user.paid = true //len(record["Оплата_"]) != 0
-		// if !user.paid {
-		// 	continue
-		// }
-		user.firstName = strings.TrimSpace(record["Имя"])
-		user.lastName = strings.TrimSpace(record["Фамилия"])
-		user.name = fmt.Sprintf("%v %v", user.lastName, user.firstName)
-		user.team = strings.TrimSpace(record["Клуб/команда"])
-		user.rating = fakeLowRating
-
-		category := strings.TrimSpace(record["Категория"])
-		user.category = category
-		// categoryFields := strings.Fields(category)
-		// if len(categoryFields) > 0 {
-		// user.category = categoryFields[0]
-		// }
-
-		user.surName = strings.TrimSpace(record["Отчество"])
-		user.phone = strings.TrimSpace(record["Телефон"])
-
-		users = append(users, user)
-
-		dlog("Participant: %v, %v", user.name, user.team)
+	err = numbering.WriteChanges(file, changes)
+	if err != nil {
+		file.Close()
+		return err
 	}
 
-	return
+	return file.Close()
 }
 
-func ratedUsersMap(users []User) (result map[string]User) {
-	result = make(map[string]User, 0)
-	for _, user := range users {
-		result[user.name] = user
-	}
-	return
+var startListColumns = participant.Columns{
+	{Field: participant.FieldName, Header: "Фамилия Имя"},
+	{Field: participant.FieldCategory, Header: "Категория"},
+	{Field: participant.FieldStartNumber, Header: "Номер"},
+	{Field: participant.FieldPaid, Header: "Оплата"},
 }
 
 var (
 	participantsFileName = ""
+	eventFileName        = ""
 	ratingFileName       = ""
 	dumpNumbers          = false
 	startList            = false
+	fuzzyScore           = 0.0
+	acceptScore          = 0.0
+	matchesFileName      = ""
+	overridesFileName    = ""
+	lockFileName         = ""
+	diffFileName         = ""
 )
 
 func main() {
 
 	flag.StringVar(&participantsFileName, "p", "", "Participants csv file")
+	flag.StringVar(&eventFileName, "event", "", "Event config json file (registration columns etc)")
 	flag.StringVar(&ratingFileName, "r", "", "Rating csv file")
 	flag.BoolVar(&dumpNumbers, "dump", false, "Dump numbers")
 	flag.BoolVar(&startList, "startList", false, "Generate start list")
+	flag.Float64Var(&fuzzyScore, "fuzzy", 0.75, "Minimal name similarity (0..1) to match a participant to the rating")
+	flag.Float64Var(&acceptScore, "accept", 0.9, "Minimal similarity to apply a fuzzy rating match without confirmation")
+	flag.StringVar(&overridesFileName, "overrides", "", "Csv file mapping registrations (name, phone or timestamp) to rating names")
+	flag.StringVar(&lockFileName, "lock", "", "Previous participants_rated.csv: keep numbers of its riders, allocate only new ones")
+	flag.StringVar(&diffFileName, "diff", "", "Write changes against the -lock file to this csv file")
+	flag.StringVar(&matchesFileName, "matches", "", "Write fuzzy rating matches to this csv file for review")
 
 	flag.Parse()
 
@@ -192,116 +180,183 @@ func main() {
 		return
 	}
 
+	config, err := event.ReadConfigFile(eventFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	ratedUsers, err := ratedUsersFromCsvFile(ratingFileName)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	participants, err := participantsUsersFromCsvFile(participantsFileName)
+	participants, err := participantsUsersFromCsvFile(participantsFileName, config.Columns)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	ratedUsersMap := ratedUsersMap(ratedUsers)
-	allUsers := make([]User, len(participants))
+	forced := make(map[int]int)
+	if len(overridesFileName) != 0 {
+		overrides, err := readOverridesFile(overridesFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		forced, err = resolveOverrides(overrides, participants, ratedUsers, overridesFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	matches := matchRatings(participants, ratedUsers, forced, fuzzyScore)
+	allUsers := make([]participant.Participant, len(participants))
 	copy(allUsers, participants)
 
-	for i, user := range participants {
-		ratedUser, ok := ratedUsersMap[user.name]
-		if ok {
-			//user.points = ratedUser.points
-			user.rating = ratedUser.rating
+	unconfirmed := 0
+
+	for _, match := range matches {
+		if match.rated < 0 && !match.overridden {
+			continue
+		}
+
+		user := participants[match.participant]
+
+		if match.overridden {
+			if match.rated < 0 {
+				dlog("Override: %q gets no rating", user.Name())
+				continue
+			}
+			user.Rating = ratedUsers[match.rated].Rating
+			allUsers[match.participant] = user
+			dlog("Override: %q -> %q (rating %v)", user.Name(), ratedUsers[match.rated].Name(), user.Rating)
+			continue
+		}
+
+		ratedUser := ratedUsers[match.rated]
+
+		status := "applied"
+		if match.score < acceptScore {
+			status = "needs confirmation"
+			unconfirmed++
+		} else {
+			user.Rating = ratedUser.Rating
+		}
+		if match.score < 1 {
+			dlog("Fuzzy rating match %.2f: %q -> %q (rating %v), %v", match.score, user.Name(), ratedUser.Name(), ratedUser.Rating, status)
+		}
+
+		allUsers[match.participant] = user
+	}
+
+	if unconfirmed != 0 {
+		dlog("Warning: %v fuzzy rating matches need confirmation and were not applied", unconfirmed)
+	}
+
+	if len(matchesFileName) != 0 {
+		err = writeMatchesFile(matchesFileName, matches, participants, ratedUsers)
+		if err != nil {
+			log.Fatal(err)
 		}
-		allUsers[i] = user
 	}
 
 	for _, user := range allUsers {
-		dlog("User: %v, rating:%v", user.name, user.rating)
+		dlog("User: %v, rating:%v", user.Name(), user.Rating)
 	}
 
-	sortedUsers := make([]User, len(allUsers))
+	sortedUsers := make([]participant.Participant, len(allUsers))
 	copy(sortedUsers, allUsers)
 
 	sort.Slice(sortedUsers, func(index1, index2 int) bool {
 		user1 := sortedUsers[index1]
 		user2 := sortedUsers[index2]
-		if user2.rating > user1.rating {
+		if user2.Rating > user1.Rating {
 			return true
-		} else if user2.rating < user1.rating {
+		} else if user2.Rating < user1.Rating {
 			return false
 		} else {
-			return strings.Compare(user1.name, user2.name) < 0
+			return strings.Compare(user1.Name(), user2.Name()) < 0
 		}
 	})
 
 	for _, user := range sortedUsers {
-		dlog("Sorted user: %v, rating:%v", user.name, user.rating)
+		dlog("Sorted user: %v, rating:%v", user.Name(), user.Rating)
 	}
 
-	allUsersMap := make(map[string]User)
+	var previous []participant.Participant
+	var held map[int64]string
 
-	number := 1
+	if len(lockFileName) != 0 {
+		previous, err = participant.ReadFile(lockFileName, participant.RatedColumns)
+		if err != nil {
+			log.Fatal(err)
+		}
+		held = lockNumbers(sortedUsers, previous)
+	}
 
-	for i := 0; i < len(sortedUsers); i++ {
+	// Bibs are printed already in lock mode, so new riders get spares first
+	err = numbering.Assign(sortedUsers, &config.Numbering, held, len(lockFileName) != 0)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		user := sortedUsers[i]
+	if len(lockFileName) != 0 {
+		changes := numbering.Diff(previous, sortedUsers)
 
-		if user.paid {
-			user.startNumber = int64(number)
-			number += 1
+		dlog("Changes against %v: %v", lockFileName, len(changes))
+		for _, change := range changes {
+			dlog("  %v", change)
 		}
 
-		allUsersMap[user.name] = user
-		sortedUsers[i] = user
+		if len(diffFileName) != 0 {
+			err = writeChangesFile(diffFileName, changes)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	allUsersMap := make(map[string]participant.Participant)
+
+	for _, user := range sortedUsers {
+		allUsersMap[user.Name()] = user
 	}
 
 	if dumpNumbers {
 		for _, user := range participants {
-			dlog("%v", allUsersMap[user.name].startNumber)
+			dlog("%v", allUsersMap[user.Name()].Value(participant.FieldStartNumber))
 		}
-	} else {
-		writer := csv.NewWriter(os.Stdout)
-		defer writer.Flush()
-
-		if startList {
-			writer.Write([]string{"Фамилия Имя", "Категория", "Номер", "Оплата"})
-
-			for _, participantUser := range participants {
-
-				user, ok := allUsersMap[participantUser.name]
-				if !ok {
-					continue
-				}
-
-				lineArray := make([]string, 0)
-				lineArray = append(lineArray, user.name)
-				lineArray = append(lineArray, user.category)
-				startNumberString := ""
-				if user.startNumber != 0 {
-					startNumberString = fmt.Sprintf("%v", user.startNumber)
-				}
-				lineArray = append(lineArray, startNumberString)
-				userPaidString := ""
-				if user.paid {
-					userPaidString = "+"
-				}
-				lineArray = append(lineArray, userPaidString)
-
-				writer.Write(lineArray)
+	} else if startList {
+		startListUsers := make([]participant.Participant, 0, len(participants))
+		for _, participantUser := range participants {
+			user, ok := allUsersMap[participantUser.Name()]
+			if !ok {
+				continue
 			}
-		} else {
-			writer.Write([]string{"number", "name", "team", "pts"})
-
-			for _, user := range sortedUsers {
-				lineArray := make([]string, 0)
-				lineArray = append(lineArray, fmt.Sprintf("%v", user.startNumber))
-				lineArray = append(lineArray, user.name)
-				lineArray = append(lineArray, user.team)
-				lineArray = append(lineArray, "")
+			startListUsers = append(startListUsers, user)
+		}
 
-				writer.Write(lineArray)
-			}
+		err = participant.Write(os.Stdout, startListUsers, startListColumns)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		// Held numbers are listed, so the next -lock run keeps them too
+		sortedUsers = append(sortedUsers, heldUsers(previous, held)...)
+
+		// Unused spares are listed without names, so they are rendered and kept track of
+		for _, number := range numbering.FreeSpares(sortedUsers, &config.Numbering, held) {
+			spare := participant.New()
+			_ = spare.SetValue(participant.FieldStartNumber, config.Numbering.Format(number))
+			sortedUsers = append(sortedUsers, spare)
 		}
 
+		// Personal and reserved numbers break rating order, the file goes by number
+		sort.SliceStable(sortedUsers, func(index1, index2 int) bool {
+			return sortedUsers[index1].StartNumber < sortedUsers[index2].StartNumber
+		})
+
+		err = participant.Write(os.Stdout, sortedUsers, participant.RatedColumns)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+// swappedPenalty lowers score of names matched with first and last name swapped.
+const swappedPenalty = 0.95
+
+// levenshtein is the edit distance between strings in runes.
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// similarity is 1 for equal strings, 0 for completely different.
+func similarity(a, b string) float64 {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+	if maxLen == 0 {
+		return 0
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(maxLen)
+}
+
+// personName is a normalized name of a participant or a rating entry.
+type personName struct {
+	last  string
+	first string
+}
+
+func newPersonName(user participant.Participant) personName {
+	return personName{
+		last:  participant.NormalizeName(user.LastName),
+		first: participant.NormalizeName(user.FirstName),
+	}
+}
+
+func (name personName) String() string {
+	return strings.TrimSpace(name.last + " " + name.first)
+}
+
+// score compares last and first names separately, so a shared first name
+// doesn't make different surnames look similar.
+func (name personName) score(other personName) (score float64, swapped bool) {
+	score = math.Min(similarity(name.last, other.last), similarity(name.first, other.first))
+	swappedScore := math.Min(similarity(name.last, other.first), similarity(name.first, other.last)) * swappedPenalty
+	if swappedScore > score {
+		return swappedScore, true
+	}
+	return score, false
+}
+
+// ratingMatch links a registered participant to a rating entry.
+type ratingMatch struct {
+	participant int // index in participants
+	rated       int // index in rating, -1 if not found
+	score       float64
+	swapped     bool
+	overridden  bool // set by the overrides file
+}
+
+// matchRatings finds a rating entry for every participant. Forced matches from
+// overrides go first, then names equal after normalization match with score 1,
+// the rest are matched one-to-one by similarity not less than minScore, best pairs first.
+func matchRatings(participants, rated []participant.Participant, forced map[int]int, minScore float64) (matches []ratingMatch) {
+	names := make([]personName, len(rated))
+	exact := make(map[string]int, len(rated))
+	for i, user := range rated {
+		names[i] = newPersonName(user)
+		exact[names[i].String()] = i
+	}
+
+	matches = make([]ratingMatch, len(participants))
+	used := make(map[int]bool)
+	participantNames := make([]personName, len(participants))
+
+	for i, user := range participants {
+		participantNames[i] = newPersonName(user)
+		matches[i] = ratingMatch{participant: i, rated: -1}
+
+		if j, ok := forced[i]; ok {
+			matches[i] = ratingMatch{participant: i, rated: j, score: 1, overridden: true}
+			if j >= 0 {
+				used[j] = true
+			}
+			continue
+		}
+
+		if j, ok := exact[participantNames[i].String()]; ok {
+			matches[i] = ratingMatch{participant: i, rated: j, score: 1}
+			used[j] = true
+		}
+	}
+
+	candidates := make([]ratingMatch, 0)
+
+	for i := range participants {
+		if matches[i].rated >= 0 || matches[i].overridden || len(participantNames[i].String()) == 0 {
+			continue
+		}
+		for j := range rated {
+			if used[j] {
+				continue
+			}
+			score, swapped := participantNames[i].score(names[j])
+			if score >= minScore {
+				candidates = append(candidates, ratingMatch{participant: i, rated: j, score: score, swapped: swapped})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].score > candidates[b].score
+	})
+
+	for _, candidate := range candidates {
+		if matches[candidate.participant].rated >= 0 || used[candidate.rated] {
+			continue
+		}
+		matches[candidate.participant] = candidate
+		used[candidate.rated] = true
+	}
+
+	return matches
+}
+
+// writeMatchesReport writes fuzzy matches, the ones below acceptScore are not
+// applied until confirmed.
+func writeMatchesReport(w io.Writer, matches []ratingMatch, participants, rated []participant.Participant, acceptScore float64) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{"score", "status", "participant", "rating name", "rating", "swapped"})
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		if match.rated < 0 || match.score == 1 {
+			continue
+		}
+
+		status := "applied"
+		if match.score < acceptScore {
+			status = "unconfirmed"
+		}
+
+		swapped := ""
+		if match.swapped {
+			swapped = "+"
+		}
+
+		ratedUser := rated[match.rated]
+		err = writer.Write([]string{
+			fmt.Sprintf("%.2f", match.score),
+			status,
+			participants[match.participant].Name(),
+			ratedUser.Name(),
+			ratedUser.Value(participant.FieldRating),
+			swapped,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+// Overrides file columns. A registration is identified by timestamp or by name
+// with optional phone, rating is "Фамилия Имя" from the rating file or "-" for
+// a rider who must not get a rating.
+const (
+	overrideName      = "name"
+	overridePhone     = "phone"
+	overrideTimestamp = "timestamp"
+	overrideRating    = "rating"
+
+	noRatingMark = "-"
+)
+
+// override maps a registration to a rating entry regardless of name matching.
+type override struct {
+	row       int
+	name      personName
+	phone     string
+	timestamp string
+	rating    personName
+	noRating  bool
+}
+
+// normalizePhone keeps last 10 digits, so "+7 908 104-08-91" equals "89081040891".
+func normalizePhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+
+	if len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	return digits
+}
+
+func parsePersonName(name string) personName {
+	var p participant.Participant
+	_ = p.SetValue(participant.FieldName, name)
+	return newPersonName(p)
+}
+
+func readOverridesFile(path string) (overrides []override, err error) {
+	records, err := participant.ReadCsvFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs participant.Errors
+
+	for i, record := range participant.CsvRecordsToMap(records) {
+		row := i + 2
+
+		o := override{
+			row:       row,
+			name:      parsePersonName(record[overrideName]),
+			phone:     normalizePhone(record[overridePhone]),
+			timestamp: strings.TrimSpace(record[overrideTimestamp]),
+		}
+
+		rating := strings.TrimSpace(record[overrideRating])
+		if rating == noRatingMark {
+			o.noRating = true
+		} else {
+			o.rating = parsePersonName(rating)
+		}
+
+		if len(o.rating.String()) == 0 && !o.noRating {
+			errs = append(errs, &participant.Error{Row: row, Column: overrideRating, Err: fmt.Errorf("empty rating name, use %q for no rating", noRatingMark)})
+			continue
+		}
+		if len(o.name.String()) == 0 && len(o.timestamp) == 0 {
+			errs = append(errs, &participant.Error{Row: row, Column: overrideName, Err: fmt.Errorf("either name or timestamp is required")})
+			continue
+		}
+
+		overrides = append(overrides, o)
+	}
+
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("%v:\n%v", path, errs)
+	}
+
+	return overrides, nil
+}
+
+func (o override) matches(user participant.Participant) bool {
+	if len(o.timestamp) != 0 {
+		return strings.TrimSpace(user.Timestamp) == o.timestamp
+	}
+	if newPersonName(user).String() != o.name.String() {
+		return false
+	}
+	return len(o.phone) == 0 || normalizePhone(user.Phone) == o.phone
+}
+
+// resolveOverrides returns forced rating index (-1 for no rating) by participant index.
+// Overrides naming riders absent in the rating are errors, ones matching no
+// registration are only reported: the rider may have cancelled.
+func resolveOverrides(overrides []override, participants, rated []participant.Participant, path string) (forced map[int]int, err error) {
+	ratingIndex := make(map[string]int, len(rated))
+	for i, user := range rated {
+		ratingIndex[newPersonName(user).String()] = i
+	}
+
+	forced = make(map[int]int)
+	var errs participant.Errors
+
+	for _, o := range overrides {
+		j := -1
+		if !o.noRating {
+			var ok bool
+			j, ok = ratingIndex[o.rating.String()]
+			if !ok {
+				errs = append(errs, &participant.Error{Row: o.row, Column: overrideRating, Err: fmt.Errorf("%q is not in the rating", o.rating)})
+				continue
+			}
+		}
+
+		found := false
+		for i, user := range participants {
+			if o.matches(user) {
+				forced[i] = j
+				found = true
+			}
+		}
+		if !found {
+			dlog("Warning: %v row %v matches no registration", path, o.row)
+		}
+	}
+
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("%v:\n%v", path, errs)
+	}
+
+	return forced, nil
+}
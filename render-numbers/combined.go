@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ivanzoid/race-numbers/bib"
+)
+
+// Orders of bibs in the combined document
+const (
+	orderNumber   = "number"
+	orderCategory = "category"
+	orderTeam     = "team"
+)
+
+var orders = []string{orderNumber, orderCategory, orderTeam}
+
+func validOrder(order string) bool {
+	for _, o := range orders {
+		if o == order {
+			return true
+		}
+	}
+	return false
+}
+
+// group returns the bib category or team the combined document is grouped by.
+func (job renderJob) group(order string) string {
+	switch order {
+	case orderCategory:
+		return job.bib.Category
+	case orderTeam:
+		return job.bib.Team
+	}
+	return ""
+}
+
+func groupTitle(group, order string) string {
+	if len(group) != 0 {
+		return group
+	}
+	if order == orderTeam {
+		return "Без команды"
+	}
+	return "Без категории"
+}
+
+// sortJobs orders bibs by number, or by group and then by number. Bibs without
+// category (team) go last.
+func sortJobs(jobs []renderJob, order string) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		gi := jobs[i].group(order)
+		gj := jobs[j].group(order)
+		if gi != gj {
+			if len(gi) == 0 || len(gj) == 0 {
+				return len(gj) == 0
+			}
+			return gi < gj
+		}
+		return jobs[i].number < jobs[j].number
+	})
+}
+
+// countBy counts bibs per group, groups are listed in the sortJobs order.
+func countBy(jobs []renderJob, order string) (groups []string, counts map[string]int) {
+	counts = make(map[string]int)
+	for _, job := range jobs {
+		group := job.group(order)
+		if _, ok := counts[group]; !ok {
+			groups = append(groups, group)
+		}
+		counts[group]++
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i]) == 0 || len(groups[j]) == 0 {
+			return len(groups[j]) == 0
+		}
+		return groups[i] < groups[j]
+	})
+
+	return groups, counts
+}
+
+// coverLines summarises counts of bibs for the cover page.
+func coverLines(jobs []renderJob, order string) (lines []string) {
+	named := 0
+	for _, job := range jobs {
+		if len(job.bib.Name) != 0 {
+			named++
+		}
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("Номеров: %v", len(jobs)),
+		fmt.Sprintf("Именных: %v", named),
+		fmt.Sprintf("Без имени: %v", len(jobs)-named),
+	)
+
+	summaries := []string{orderCategory}
+	if order == orderTeam {
+		summaries = append(summaries, orderTeam)
+	}
+
+	for _, summary := range summaries {
+		title := "По категориям:"
+		if summary == orderTeam {
+			title = "По командам:"
+		}
+		lines = append(lines, "", title)
+
+		groups, counts := countBy(jobs, summary)
+		for _, group := range groups {
+			lines = append(lines, fmt.Sprintf("%v: %v", groupTitle(group, summary), counts[group]))
+		}
+	}
+
+	return lines
+}
+
+// renderCombined renders all bibs to a single document after a cover page, with
+// bookmarks per number and per category (team). Bibs ordered by number get a
+// separate "По категориям" section of bookmarks. A failed bib fails the whole document.
+func renderCombined(template *bib.Template, jobs []renderJob, order string, fileName string, progress func(done, total int, result renderResult)) (results []renderResult, err error) {
+	renderer, err := bib.NewRenderer(template, fontDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = renderer.SetBackground(bgFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	sortJobs(jobs, order)
+
+	err = renderer.AddTextPage(coverLines(jobs, order))
+	if err != nil {
+		return nil, err
+	}
+	renderer.Bookmark("Сводка", 0)
+
+	_, counts := countBy(jobs, order)
+	pages := make([]int, len(jobs))
+
+	for i, job := range jobs {
+		truncations, err := renderer.AddBib(job.bib)
+		result := renderResult{job: job, truncations: truncations, err: err}
+		results = append(results, result)
+
+		if progress != nil {
+			progress(i+1, len(jobs), result)
+		}
+		if err != nil {
+			return results, err
+		}
+
+		level := 0
+		if order != orderNumber {
+			group := job.group(order)
+			if i == 0 || group != jobs[i-1].group(order) {
+				renderer.Bookmark(fmt.Sprintf("%v (%v)", groupTitle(group, order), counts[group]), 0)
+			}
+			level = 1
+		}
+
+		renderer.Bookmark(job.title(), level)
+		pages[i] = renderer.PageNo()
+	}
+
+	if order == orderNumber && len(jobs) != 0 {
+		bookmarkCategories(renderer, jobs, pages)
+	}
+
+	return results, renderer.OutputFileAndClose(fileName)
+}
+
+func (job renderJob) title() string {
+	if len(job.bib.Name) != 0 {
+		return job.bib.Number + " " + job.bib.Name
+	}
+	return job.bib.Number
+}
+
+// bookmarkCategories adds a section of bookmarks by category pointing to the
+// pages of bibs rendered in number order.
+func bookmarkCategories(renderer *bib.Renderer, jobs []renderJob, pages []int) {
+	indexes := make([]int, len(jobs))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		ga := jobs[indexes[a]].group(orderCategory)
+		gb := jobs[indexes[b]].group(orderCategory)
+		if ga != gb && (len(ga) == 0 || len(gb) == 0) {
+			return len(gb) == 0
+		}
+		return ga < gb
+	})
+
+	_, counts := countBy(jobs, orderCategory)
+
+	renderer.BookmarkPage("По категориям", 0, pages[indexes[0]])
+	for n, i := range indexes {
+		group := jobs[i].group(orderCategory)
+		if n == 0 || group != jobs[indexes[n-1]].group(orderCategory) {
+			renderer.BookmarkPage(fmt.Sprintf("%v (%v)", groupTitle(group, orderCategory), counts[group]), 1, pages[i])
+		}
+		renderer.BookmarkPage(jobs[i].title(), 2, pages[i])
+	}
+}
@@ -1,15 +1,17 @@
 package main
 
 import (
-	"encoding/csv"
-	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
-	"strconv"
+	"runtime"
+	"sort"
 	"strings"
+
+	"github.com/ivanzoid/race-numbers/bib"
+	"github.com/ivanzoid/race-numbers/event"
+	"github.com/ivanzoid/race-numbers/participant"
 )
 
 func dlog(format string, args ...interface{}) {
@@ -17,132 +19,46 @@ func dlog(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "\n")
 }
 
-func runProgram1(run, print bool, program string, args ...string) (string, error) {
-	outStrings, err := runProgram(run, print, program, args...)
-	if err != nil {
-		return "", err
-	}
-	if len(outStrings) == 0 {
-		return "", nil
-	}
-
-	return outStrings[0], nil
+func participantsUsersFromCsvFile(csvFilePath string) (users []participant.Participant, err error) {
+	return participant.ReadFile(csvFilePath, participant.RatedColumns)
 }
 
-func runProgram(run, print bool, program string, args ...string) ([]string, error) {
-
-	if print {
-		dlog("Running %v", cmdString(program, args))
-	}
-
-	cmd := exec.Command(program, args...)
-
-	outString := ""
-
-	out, err := cmd.Output()
-	if len(out) != 0 {
-		outString = string(out)
-	}
+func renderBib(template *bib.Template, numberBib bib.Bib, outputFileName string) (truncations []bib.Truncation, err error) {
+	renderer, err := bib.NewRenderer(template, fontDir)
 	if err != nil {
-		outInsert := ""
-		if len(outString) != 0 {
-			outInsert = fmt.Sprintf(". %v", outString)
-		}
-		return nil, errors.New(fmt.Sprintf("Error running \"%v\": %v.%v", cmdString(program, args), err, outInsert))
-	}
-
-	if len(outString) == 0 {
-		return nil, nil
-	}
-
-	outStrings := strings.Split(outString, "\n")
-	return outStrings, nil
-}
-
-func cmdString(program string, args []string) string {
-	comps := make([]string, 0)
-
-	comps = append(comps, program)
-
-	for _, arg := range args {
-		if strings.Contains(arg, " ") {
-			comps = append(comps, fmt.Sprintf("'%v'", arg))
-		} else {
-			comps = append(comps, arg)
-		}
+		return nil, err
 	}
 
-	result := strings.Join(comps, " ")
-	return result
-}
-
-func readCsvFile(csvFilePath string) (records [][]string, err error) {
-	file, err := os.Open(csvFilePath)
+	err = renderer.SetBackground(bgFileName)
 	if err != nil {
 		return nil, err
 	}
 
-	defer file.Close()
-
-	csvReader := csv.NewReader(file)
-	//csvReader.Comma = ';'
-
-	records, err = csvReader.ReadAll()
-
-	return
-}
-
-func csvRecordsToMap(records [][]string) (result []map[string]string) {
-
-	if len(records) == 0 {
-		return nil
-	}
-
-	result = make([]map[string]string, 0, len(records))
-
-	var header []string
-
-	for line, record := range records {
-		if line == 0 {
-			header = record
-			continue
-		}
-
-		recordMap := make(map[string]string)
-
-		for col, value := range record {
-			if col >= len(header) {
-				break
-			}
-			colName := header[col]
-			recordMap[colName] = value
-		}
-
-		result = append(result, recordMap)
-	}
-
-	return
-}
-
-func participantsUsersFromCsvFile(csvFilePath string) (mapRecords []map[string]string, err error) {
-
-	records, err := readCsvFile(csvFilePath)
+	truncations, err = renderer.AddBib(numberBib)
 	if err != nil {
 		return nil, err
 	}
 
-	mapRecords = csvRecordsToMap(records)
-
-	return
+	return truncations, renderer.OutputFileAndClose(outputFileName)
 }
 
 var (
 	participantsFileName = ""
 	bgFileName           = ""
 	outDir               = ""
-	tmpDir               = ""
+	fontDir              = ""
+	templateFileName     = ""
 	limit                = 0
+	workers              = 0
 	onlyPresent          = false
+	combinedFileName     = ""
+	order                = ""
+	eventID              = ""
+	eventFileName        = ""
+	selectNumbers        = ""
+	selectCategories     = ""
+	selectTeams          = ""
+	diffFileName         = ""
 )
 
 func main() {
@@ -150,9 +66,19 @@ func main() {
 	flag.StringVar(&participantsFileName, "p", "", "Participants csv file")
 	flag.StringVar(&bgFileName, "bg", "", "Background pdf file")
 	flag.StringVar(&outDir, "out", "out", "Output dir")
-	flag.StringVar(&tmpDir, "tmp", "tmp", "Tmp dir")
-	flag.IntVar(&limit, "limit", 250, "Limit amount of numbers generated")
+	flag.StringVar(&fontDir, "fonts", "../start-number-draw/fonts", "Fonts dir")
+	flag.StringVar(&templateFileName, "template", "../start-number-draw/templates/default.json", "Bib layout template json")
+	flag.IntVar(&limit, "limit", 250, "Generate numbers from 1 to limit, numbers of participants above it are generated too")
+	flag.IntVar(&workers, "workers", runtime.NumCPU(), "Number of bibs rendered in parallel")
 	flag.BoolVar(&onlyPresent, "present", false, "Generate numbers only present in Participants file")
+	flag.StringVar(&combinedFileName, "combined", "", "Render all numbers to this single pdf with a cover page and bookmarks instead of a file per number")
+	flag.StringVar(&eventFileName, "event", "", "Event config json file (number format, excluded numbers, spares)")
+	flag.StringVar(&eventID, "eventId", "", "Event id, may be encoded in QR code or barcode of the template")
+	flag.StringVar(&selectNumbers, "numbers", "", "Render only these numbers, e.g. \"1-20,45,100-\"")
+	flag.StringVar(&selectCategories, "category", "", "Render only bibs of these categories, comma separated, * matches any text: \"Ж*,М18-22\"")
+	flag.StringVar(&selectTeams, "team", "", "Render only bibs of these teams, comma separated")
+	flag.StringVar(&diffFileName, "diff", "", "Render only numbers changed according to this csv of rate-participants -diff or diff-numbers")
+	flag.StringVar(&order, "order", orderNumber, fmt.Sprintf("Order of numbers in the combined pdf: %v", strings.Join(orders, ", ")))
 
 	flag.Parse()
 
@@ -161,47 +87,170 @@ func main() {
 		return
 	}
 
+	if !validOrder(order) {
+		log.Fatalf("Unknown order %q, expected one of: %v", order, strings.Join(orders, ", "))
+	}
+
+	config, err := event.ReadConfigFile(eventFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	selected, err := newSelection(selectNumbers, diffFileName, selectCategories, selectTeams)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	users, err := participantsUsersFromCsvFile(participantsFileName)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	for i := 0; i < limit; i++ {
+	template, err := bib.ReadTemplateFile(templateFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		var name string
-		number := i + 1
-		var numberString string
-		var team string
+	usersByNumber := make(map[int64]participant.Participant, len(users))
+	numbers := make([]int64, 0, limit)
 
-		if i < len(users) {
-			user := users[i]
+	for _, user := range users {
+		if user.StartNumber == 0 {
+			continue
+		}
+		if other, ok := usersByNumber[user.StartNumber]; ok {
+			log.Fatalf("Number %v is given to both %q and %q", user.StartNumber, other.Name(), user.Name())
+		}
+		usersByNumber[user.StartNumber] = user
+		numbers = append(numbers, user.StartNumber)
+	}
 
-			numberString = user["number"]
-			name = user["name"]
-			team = user["team"]
-		} else {
-			if onlyPresent {
-				break
+	// Numbers without participants are rendered without name up to the limit
+	if !onlyPresent {
+		for number := int64(1); number <= int64(limit); number++ {
+			if _, ok := usersByNumber[number]; !ok && !config.Numbering.Exclude.Contains(number) {
+				numbers = append(numbers, number)
 			}
-			numberString = fmt.Sprintf("%v", number)
 		}
+	}
+
+	// Spares are rendered even if the participants file doesn't list them
+	for _, number := range config.Numbering.Spares.Numbers(0) {
+		if _, ok := usersByNumber[number]; ok {
+			continue
+		}
+		if onlyPresent || number > int64(limit) {
+			numbers = append(numbers, number)
+		}
+	}
 
-		parsedNumber, _ := strconv.ParseInt(numberString, 10, 64)
+	// Explicitly selected numbers are rendered even if they are not listed above
+	for _, number := range selected.explicit() {
+		if _, ok := usersByNumber[number]; ok || config.Numbering.Exclude.Contains(number) {
+			continue
+		}
+		if (onlyPresent || number > int64(limit)) && !config.Numbering.Spares.Contains(number) {
+			numbers = append(numbers, number)
+		}
+	}
 
-		tmpOutputFileName := fmt.Sprintf("%v/%03d.pdf", tmpDir, parsedNumber)
+	sort.Slice(numbers, func(i, j int) bool {
+		return numbers[i] < numbers[j]
+	})
 
-		out, err := runProgram1(true, true, "../start-number-draw/start-number-draw", "--number", numberString, "--name", name, "--team", team, "-o", tmpOutputFileName)
-		if err != nil {
-			dlog("Error: %v\n", err)
-			dlog("%v", out)
+	jobs := make([]renderJob, 0, len(numbers))
+
+	for _, number := range numbers {
+		var selectedUser *participant.Participant
+		if user, ok := usersByNumber[number]; ok {
+			selectedUser = &user
+		}
+		if !selected.matches(number, selectedUser) {
+			continue
 		}
 
-		outputFileName := fmt.Sprintf("%v/%03d.pdf", outDir, parsedNumber)
+		numberBib := bib.Bib{Number: config.Numbering.Format(number), Event: eventID}
+		numberBib.Spare = config.Numbering.Spares.Contains(number)
 
-		out, err = runProgram1(true, true, "pdftk", tmpOutputFileName, "background", bgFileName, "output", outputFileName)
+		if user, ok := usersByNumber[number]; ok {
+			numberBib.Number = user.Value(participant.FieldStartNumber)
+			numberBib.Name = user.Name()
+			numberBib.Team = user.Team
+			numberBib.Category = user.Category
+			numberBib.ID = user.Timestamp
+			numberBib.Spare = numberBib.Spare && len(user.Name()) == 0
+		}
+
+		job := renderJob{
+			bib:    numberBib,
+			number: number,
+		}
+		if len(combinedFileName) == 0 {
+			job.outputFileName = fmt.Sprintf("%v/%03d.pdf", outDir, number)
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	progress := func(done, total int, result renderResult) {
+		status := "ok"
+		if result.err != nil {
+			status = "FAILED"
+		}
+		what := result.job.outputFileName
+		if len(what) == 0 {
+			what = result.job.bib.Number
+		}
+		dlog("[%v/%v] %v %v", done, total, what, status)
+	}
+
+	var results []renderResult
+	out := outDir
+
+	if len(combinedFileName) != 0 {
+		out = combinedFileName
+		results, err = renderCombined(template, jobs, order, combinedFileName, progress)
 		if err != nil {
-			dlog("Error: %v\n", err)
-			dlog("%v", out)
+			log.Fatal(err)
+		}
+	} else {
+		results = renderAll(template, jobs, workers, progress)
+	}
+
+	truncated := make([]string, 0)
+	failed := make([]string, 0)
+
+	for _, result := range results {
+		numberBib := result.job.bib
+		for _, truncation := range result.truncations {
+			line := fmt.Sprintf("%v %v: %v %q -> %q", numberBib.Number, numberBib.Name, truncation.Field, truncation.Text, truncation.Drawn)
+			if len(truncation.Missing) != 0 {
+				line += fmt.Sprintf(", can't draw %q", truncation.Missing)
+			}
+			if len(truncation.Empty) != 0 {
+				line = fmt.Sprintf("%v %v: %v %q is not drawn, empty %v", numberBib.Number, numberBib.Name, truncation.Field, truncation.Text, truncation.Empty)
+			}
+			truncated = append(truncated, line)
+		}
+		if result.err != nil {
+			failed = append(failed, fmt.Sprintf("%v %v: %v", numberBib.Number, numberBib.Name, result.err))
+		}
+	}
+
+	if len(truncated) != 0 {
+		dlog("Warning: texts didn't fit and were truncated or have characters which can't be drawn, or codes have empty fields, check these numbers before printing:")
+		for _, line := range truncated {
+			dlog("  %v", line)
+		}
+	}
+
+	dlog("Rendered %v of %v numbers to %v", len(results)-len(failed), len(results), out)
+
+	if len(failed) != 0 {
+		dlog("Failed to render %v numbers:", len(failed))
+		for _, line := range failed {
+			dlog("  %v", line)
 		}
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/ivanzoid/race-numbers/bib"
+)
+
+type renderJob struct {
+	bib            bib.Bib
+	number         int64
+	outputFileName string // empty when rendered to the combined document
+}
+
+type renderResult struct {
+	job         renderJob
+	truncations []bib.Truncation
+	err         error
+}
+
+// renderAll renders jobs on a pool of workers. progress is called from the
+// calling goroutine after each bib, results are in the order of jobs.
+func renderAll(template *bib.Template, jobs []renderJob, workers int, progress func(done, total int, result renderResult)) (results []renderResult) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	finished := make(chan int)
+
+	results = make([]renderResult, len(jobs))
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				job := jobs[i]
+				truncations, err := renderBib(template, job.bib, job.outputFileName)
+				results[i] = renderResult{job: job, truncations: truncations, err: err}
+				finished <- i
+			}
+		}()
+	}
+
+	go func() {
+		for i := range jobs {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+		close(finished)
+	}()
+
+	done := 0
+	for i := range finished {
+		done++
+		if progress != nil {
+			progress(done, len(jobs), results[i])
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/ivanzoid/race-numbers/category"
+	"github.com/ivanzoid/race-numbers/numbering"
+	"github.com/ivanzoid/race-numbers/participant"
+)
+
+// selection limits which bibs are rendered. Empty criteria select everything,
+// given ones must all match.
+type selection struct {
+	byNumber   bool              // -numbers or -diff given
+	numbers    numbering.Ranges  // -numbers and numbers of the -diff file
+	categories category.Patterns // like "Ж*"
+	teams      []string          // case insensitive
+}
+
+// splitList splits a comma separated flag value, empty items are skipped.
+func splitList(value string) (items []string) {
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if len(item) != 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func newSelection(numbers, diffFileName, categories, teams string) (s selection, err error) {
+	s.numbers, err = numbering.ParseRanges(numbers)
+	if err != nil {
+		return s, err
+	}
+	s.byNumber = len(s.numbers) != 0
+
+	if len(diffFileName) != 0 {
+		file, err := os.Open(diffFileName)
+		if err != nil {
+			return s, err
+		}
+		defer file.Close()
+
+		changes, err := numbering.ReadChanges(file)
+		if err != nil {
+			return s, err
+		}
+		changed := numbering.Numbers(changes)
+		if len(changed) == 0 {
+			dlog("Warning: no changes in %v, nothing to render", diffFileName)
+		}
+
+		// Diff numbers narrow -numbers if both are given
+		if s.byNumber {
+			var both []int64
+			for _, number := range changed.Numbers(0) {
+				if s.numbers.Contains(number) {
+					both = append(both, number)
+				}
+			}
+			changed = numbering.RangesOf(both)
+		}
+		s.numbers = changed
+		s.byNumber = true
+	}
+
+	s.categories = splitList(categories)
+	if err := s.categories.Validate(); err != nil {
+		return s, err
+	}
+
+	s.teams = splitList(teams)
+
+	return s, nil
+}
+
+// explicit lists numbers of bounded ranges, they are rendered even if nobody
+// has them, above -limit or with -present.
+func (s selection) explicit() []int64 {
+	var bounded numbering.Ranges
+	for _, r := range s.numbers {
+		if !r.OpenEnded() {
+			bounded = append(bounded, r)
+		}
+	}
+	return bounded.Numbers(0)
+}
+
+// matches tells if the bib of the number is selected, user is nil for bibs
+// without a participant.
+func (s selection) matches(number int64, user *participant.Participant) bool {
+	if s.byNumber && !s.numbers.Contains(number) {
+		return false
+	}
+	if len(s.categories) == 0 && len(s.teams) == 0 {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	if len(s.categories) != 0 && !s.categories.Match(user.Category) {
+		return false
+	}
+	if len(s.teams) != 0 && !contains(s.teams, strings.ToLower(strings.TrimSpace(user.Team))) {
+		return false
+	}
+	return true
+}
+
+func contains(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
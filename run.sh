@@ -1,9 +1,5 @@
 #!/bin/bash
 
-program_exists () {
-	type "$1" &> /dev/null ;
-}
-
 main()
 {
     # REGISTERED_USERS_GOOGLE_SHEET_ID='18565fZBloJgaOP9YJrCwLUIYArc2HUBqVEljTlhH2TA'
@@ -15,29 +11,15 @@ main()
     # exit
 
     # cd rate-participants
-    # go run main.go -p ../_data/participants.csv -r ../_data/rating.csv > ../_data/participants_rated.csv
+    # go run . -event ../_data/event.json -p ../_data/participants.csv -r ../_data/rating.csv -overrides ../_data/overrides.csv -matches ../_data/matches.csv > ../_data/participants_rated.csv
     # cd ..
 
-    if ! program_exists pdftk; then
-        echo "Please install pdftk"
-        exit 2
-    fi
-
-    cd start-number-draw
-    go build
-    cd ..
-
     cd render-numbers
-    RENDER_TMP_DIR='../_tmp'
     RENDER_OUT_DIR='../_out'
-    rm "${RENDER_TMP_DIR}/*"
     rm "${RENDER_OUT_DIR}/*"
-    mkdir -p "$RENDER_TMP_DIR"
     mkdir -p "$RENDER_OUT_DIR"
 
-    go run main.go -limit 150 -bg ../_data/number_bg.pdf -p ../_data/participants_rated.csv -tmp "$RENDER_TMP_DIR" -out "$RENDER_OUT_DIR"
-
-    rm "${RENDER_TMP_DIR}/*"
+    go run . -event ../_data/event.json -limit 150 -bg ../_data/number_bg.pdf -p ../_data/participants_rated.csv -out "$RENDER_OUT_DIR"
 }
 
 main "$@"
@@ -2,38 +2,39 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
-	"github.com/jung-kurt/gofpdf"
-)
-
-const (
-	pageWidth         = 200
-	pageHeight        = 140.7
-	fontHelvetica     = "Helvetica"
-	fontHelveticaBold = "Helvetica-Bold"
+	"github.com/ivanzoid/race-numbers/bib"
 )
 
 var (
-	number   string
-	name     string
-	team     string
-	fileName string
+	number       string
+	name         string
+	team         string
+	category     string
+	eventID      string
+	participant  string
+	fileName     string
+	fontDir      string
+	templateFile string
+	bgFileName   string
 )
 
-func setFont(pdf *gofpdf.Fpdf, family, style string, fontSize float64) (lineHeight float64) {
-	pdf.SetFont(family, style, fontSize)
-	return pdf.PointConvert(fontSize)
-}
-
 func main() {
 
 	flag.StringVar(&number, "number", "", "")
 	flag.StringVar(&name, "name", "", "")
 	flag.StringVar(&team, "team", "", "")
+	flag.StringVar(&category, "category", "", "")
+	flag.StringVar(&eventID, "eventId", "", "Event id, may be encoded in QR code or barcode")
+	flag.StringVar(&participant, "id", "", "Participant id, may be encoded in QR code or barcode")
 	flag.StringVar(&fileName, "o", "out.pdf", "Output filename")
+	flag.StringVar(&fontDir, "fonts", "", "Fonts dir (default is fonts next to executable)")
+	flag.StringVar(&templateFile, "template", "", "Bib layout template json (default is templates/default.json next to executable)")
+	flag.StringVar(&bgFileName, "bg", "", "Background pdf file (optional)")
 	flag.Parse()
 
 	executablePath, err := os.Executable()
@@ -41,80 +42,54 @@ func main() {
 		panic(err)
 	}
 	executableDir := filepath.Dir(executablePath)
-	fontDirStr := filepath.Join(executableDir, "fonts")
-
-	pdf := gofpdf.NewCustom(&gofpdf.InitType{
-		UnitStr:    "mm",
-		Size:       gofpdf.SizeType{Wd: pageWidth, Ht: pageHeight},
-		FontDirStr: fontDirStr,
-	})
 
-	pdf.SetMargins(0, 0, 0)
-	pdf.SetAutoPageBreak(false, 0)
-	pdf.AddFont(fontHelvetica, "", "helvetica_1251.json")
-	pdf.AddFont(fontHelveticaBold, "", "helveticab.json")
-	pdf.AddPage()
-
-	translator := pdf.UnicodeTranslatorFromDescriptor("cp1251")
+	if len(fontDir) == 0 {
+		fontDir = filepath.Join(executableDir, "fonts")
+	}
+	if len(templateFile) == 0 {
+		templateFile = filepath.Join(executableDir, "templates", "default.json")
+	}
 
-	vmarginTop := 22.0
-	vmarginBottom := 50.0
-	hmargin := 7.0
+	template, err := bib.ReadTemplateFile(templateFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	if len(name) != 0 {
-		// pdf.SetTextColor(0xC0, 0x00, 0x00)
-		pdf.SetTextColor(0, 0, 0)
-		lineHeight := setFont(pdf, fontHelvetica, "", 40)
-		pdf.SetY(vmarginTop + 7)
-		pdf.SetX(hmargin)
-		// pdf.MultiCell(pageWidth, lineHeight, translator(name), "", "C", false)
-		pdf.MultiCell(pageWidth-hmargin, lineHeight, translator(name), "", "L", false)
+	renderer, err := bib.NewRenderer(template, fontDir)
+	if err != nil {
+		log.Fatalln(err)
 	}
 
-	mul := 0.6
-	withTeamHei := 276.0
-	withNameHei := 324.0
-	noTeamNameShortHei := 380.0
-	noTeamNameLongHei := 320.0
+	err = renderer.SetBackground(bgFileName)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	if len(number) != 0 {
-		pdf.SetTextColor(0, 0, 0)
-		if len(team) != 0 {
-			setFont(pdf, fontHelveticaBold, "", withTeamHei*mul)
-			pdf.SetY(vmarginTop + (pageHeight-vmarginBottom)/2 + 6)
-			pdf.SetX(0)
-			pdf.MultiCell(pageWidth, 0, translator(number), "", "C", false)
-		} else if len(name) != 0 {
-			setFont(pdf, fontHelveticaBold, "", withNameHei*mul)
-			pdf.SetY(vmarginTop + (pageHeight-vmarginBottom)/2 + 14)
-			pdf.SetX(0)
-			pdf.MultiCell(pageWidth, 0, translator(number), "", "C", false)
-		} else {
-			if len(number) <= 2 {
-				setFont(pdf, fontHelveticaBold, "", noTeamNameShortHei*mul)
-				pdf.SetY(vmarginTop + (pageHeight-vmarginBottom)/2 + 6.5)
-				pdf.SetX(0)
-				pdf.MultiCell(pageWidth, 0, translator(number), "", "C", false)
-			} else {
-				setFont(pdf, fontHelveticaBold, "", noTeamNameLongHei*mul)
-				pdf.SetY(vmarginTop + (pageHeight-vmarginBottom)/2 + 6.5)
-				pdf.SetX(0)
-				pdf.MultiCell(pageWidth, 0, translator(number), "", "C", false)
-			}
-		}
+	truncations, err := renderer.AddBib(bib.Bib{
+		Number:   number,
+		Name:     name,
+		Team:     team,
+		Category: category,
+		Event:    eventID,
+		ID:       participant,
+	})
+	if err != nil {
+		log.Fatalln(err)
 	}
 
-	if len(team) != 0 {
-		pdf.SetTextColor(0, 0, 0)
-		// pdf.SetTextColor(0xC0, 0x00, 0x00)
-		setFont(pdf, fontHelvetica, "", 32)
-		pdf.SetY(pageHeight - vmarginBottom + 11)
-		pdf.SetX(hmargin)
-		// pdf.MultiCell(pageWidth, 0, translator(team), "", "C", false)
-		pdf.MultiCell(pageWidth-hmargin*2, 0, translator(team), "", "R", false)
+	// Printed to stdout so render-numbers can collect them
+	for _, truncation := range truncations {
+		if len(truncation.Empty) != 0 {
+			fmt.Printf("Skipped %v %q: empty %v\n", truncation.Field, truncation.Text, truncation.Empty)
+			continue
+		}
+		fmt.Printf("Truncated %v: %q -> %q\n", truncation.Field, truncation.Text, truncation.Drawn)
+		if len(truncation.Missing) != 0 {
+			fmt.Printf("Can't draw %v characters: %q\n", truncation.Field, truncation.Missing)
+		}
 	}
 
-	err = pdf.OutputFileAndClose(fileName)
+	err = renderer.OutputFileAndClose(fileName)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -7,6 +7,9 @@ go build
 ./start-number-draw -name "Иван Иванов" -number '999' -team 'ЦР' -o out/number1.pdf
 ./start-number-draw -name "Иван Иванов" -number '5' -o out/number2.pdf
 ./start-number-draw -number '555' -o out/number3.pdf
-pdftk out/number1.pdf background ../_data/number_bg.pdf output out/out1.pdf
-pdftk out/number2.pdf background ../_data/number_bg.pdf output out/out2.pdf
-pdftk out/number3.pdf background ../_data/number_bg.pdf output out/out3.pdf
+./start-number-draw -name "Әлия Müller" -number '12' -team 'Қазақстан' -o out/number4.pdf
+./start-number-draw -name "Иван Иванов" -number '999' -team 'ЦР' -bg ../_data/number_bg.pdf -o out/out1.pdf
+./start-number-draw -name "Иван Иванов" -number '5' -bg ../_data/number_bg.pdf -o out/out2.pdf
+./start-number-draw -number '555' -bg ../_data/number_bg.pdf -o out/out3.pdf
+./start-number-draw -name "Әлия Müller" -number '12' -team 'Қазақстан' -bg ../_data/number_bg.pdf -o out/out4.pdf
+./start-number-draw -name "Иван Иванов" -number '999' -team 'ЦР' -template templates/qr.json -bg ../_data/number_bg.pdf -o out/out5.pdf